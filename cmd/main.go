@@ -6,13 +6,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/kazimsarikaya/go_react_mui/internal/config"
+	"github.com/kazimsarikaya/go_react_mui/internal/health"
+	"github.com/kazimsarikaya/go_react_mui/internal/kube"
 	"github.com/kazimsarikaya/go_react_mui/internal/logger"
+	"github.com/kazimsarikaya/go_react_mui/internal/tracing"
 	"github.com/kazimsarikaya/go_react_mui/internal/webserver"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -20,7 +26,8 @@ import (
 
 // define cobra/viper root command
 var (
-	cfgFile string
+	cfgFile       string
+	catalogOutput string
 
 	rootCmd = &cobra.Command{
 		Use:   "app",
@@ -32,6 +39,11 @@ var (
 		Use:   "server",
 		Short: "Start the app server",
 		Long:  `Start the app server with the specified options`,
+		// only the server needs its settings to be valid
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return config.GetConfigBuilder().Validate()
+		},
+		SilenceUsage: true,
 		Run: func(cmd *cobra.Command, args []string) {
 			err := cmdServer()
 
@@ -51,6 +63,15 @@ var (
 			fmt.Println("go version:", config.GetConfig().GetGoVersion())
 		},
 	}
+
+	actionsCmd = &cobra.Command{
+		Use:   "actions",
+		Short: "Write the api action catalog",
+		Long:  `Write the api action catalog with JSON schemas of requests and responses`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdActions()
+		},
+	}
 )
 
 func initConfig() {
@@ -73,11 +94,67 @@ func initConfig() {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
 
-	config.GetConfigBuilder().SyncConfig()
+	err := config.GetConfigBuilder().SyncConfig()
+	cobra.CheckErr(err)
+
+	c := config.GetConfig()
+
+	err = logger.Setup(logger.Options{
+		Format: c.GetLogFormat(),
+		Output: c.GetLogOutput(),
+		File:   c.GetLogFile(),
+		Rotate: logRotateOptions(c),
+	})
+	cobra.CheckErr(err)
+}
+
+func logRotateOptions(c config.Config) logger.RotateOptions {
+	return logger.RotateOptions{
+		MaxSize:    int64(c.GetLogMaxSize()) << 20,
+		Interval:   c.GetLogRotateInterval(),
+		MaxBackups: c.GetLogMaxBackups(),
+		MaxAge:     c.GetLogMaxAge(),
+		Compress:   c.GetLogCompress(),
+	}
+}
+
+// setupKube builds the Kubernetes client and checks it can talk to the API
+// server, so a wrong address or credential fails the start instead of every
+// request. Running without Kubernetes is allowed.
+func setupKube(c config.Config) error {
+	err := kube.Setup(kube.Options{
+		APIServer:   c.GetKubeApiServer(),
+		CAFile:      c.GetKubeCAFile(),
+		AuthMode:    c.GetKubeAuthMode(),
+		UserPrefix:  c.GetKubeUserPrefix(),
+		GroupPrefix: c.GetKubeGroupPrefix(),
+	})
+
+	if errors.Is(err, kube.ErrNotConfigured) {
+		slog.Info("Kubernetes is not configured, kubernetes actions are disabled")
+		return nil
+	}
+
+	if err != nil {
+		slog.Error("Error setting up kubernetes client", "error", err)
+		return err
+	}
+
+	client := kube.GetClient()
+
+	v, err := client.Validate(context.Background())
+
+	if err != nil {
+		slog.Error("Error connecting to kubernetes API server", "server", client.Server(), "error", err)
+		return err
+	}
+
+	slog.Info("Connected to kubernetes API server", "server", client.Server(), "version", v.GitVersion, "auth_mode", client.AuthMode())
+
+	return nil
 }
 
 func init() {
-	slog.SetDefault(logger.DefaultSLogger)
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.app.yaml)")
@@ -96,16 +173,122 @@ func init() {
 	rootCmd.AddCommand(serverCmd)
 
 	rootCmd.AddCommand(versionCmd)
+
+	actionsCmd.Flags().StringVarP(&catalogOutput, "output", "o", "", "output file (default is stdout)")
+
+	rootCmd.AddCommand(actionsCmd)
+}
+
+func cmdActions() error {
+	if catalogOutput == "" {
+		return webserver.WriteActionCatalog(os.Stdout)
+	}
+
+	f, err := os.Create(catalogOutput)
+
+	if err != nil {
+		return err
+	}
+
+	err = webserver.WriteActionCatalog(f)
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// applyLogLevel sets the level of the application log, debug mode forces
+// the debug level.
+func applyLogLevel(c config.Config) {
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(c.GetLogLevel())); err != nil {
+		level = slog.LevelInfo
+	}
+
+	if c.GetDebug() {
+		level = slog.LevelDebug
+	}
+
+	logger.LogLevel.Set(level)
+}
+
+// watchConfig applies settings of a changed config file to the running
+// server. SIGHUP reloads the config too and reopens the log file after
+// logrotate moved it.
+func watchConfig() {
+	config.Subscribe(func(c config.Config, changed []string) {
+		applyLogLevel(c)
+	})
+
+	if err := config.WatchConfigFile(); err != nil {
+		slog.Error("Error watching config file, use SIGHUP to reload", "error", err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		for range hup {
+			if err := config.Reload(); err != nil {
+				slog.Error("Error reloading config", "error", err)
+			}
+
+			if err := logger.Reopen(); err != nil {
+				slog.Error("Error reopening log file", "error", err)
+			} else {
+				slog.Info("Log file reopened")
+			}
+		}
+	}()
 }
 
 func cmdServer() error {
 	config := config.GetConfig()
 
 	slog.Info("config", "server_port", config.GetServerPort())
+	slog.Info("config", "listen", config.GetListen())
+	slog.Info("config", "admin_listen", config.GetAdminListen())
+	slog.Info("config", "public_metrics", config.GetPublicMetrics())
 	slog.Info("config", "debug", config.GetDebug())
 
-	if config.GetDebug() {
-		logger.LogLevel.Set(slog.LevelDebug)
+	applyLogLevel(config)
+
+	err := logger.SetupAccessLog(logger.AccessLogOptions{
+		Format:       config.GetAccessLogFormat(),
+		Template:     config.GetAccessLogTemplate(),
+		Output:       config.GetAccessLogOutput(),
+		File:         config.GetAccessLogFile(),
+		Rotate:       logRotateOptions(config),
+		ExcludePaths: config.GetAccessLogExclude(),
+		SampleRate:   config.GetAccessLogSampleRate(),
+	})
+
+	if err != nil {
+		slog.Error("Error setting up access log", "error", err)
+		return err
+	}
+
+	shutdownTracing, err := tracing.Setup(tracing.Options{
+		Endpoint:       config.GetTraceEndpoint(),
+		Sampler:        config.GetTraceSampler(),
+		SamplerRatio:   config.GetTraceSamplerRatio(),
+		ServiceName:    config.GetTraceServiceName(),
+		ServiceVersion: config.GetVersion(),
+	})
+
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		return err
+	}
+
+	err = setupKube(config)
+
+	if err != nil {
+		_ = shutdownTracing(context.Background())
+		return err
 	}
 
 	srv, err := webserver.StartWebServer()
@@ -114,15 +297,32 @@ func cmdServer() error {
 		return err
 	}
 
+	adminSrv, err := webserver.StartAdminServer()
+
+	if err != nil {
+		_ = srv.Close()
+		return err
+	}
+
+	watchConfig()
+
 	c := make(chan os.Signal, 1)
 
-	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
-	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
-	signal.Notify(c, os.Interrupt)
+	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C) or
+	// SIGTERM, which Kubernetes sends to stop a pod.
+	// SIGKILL or SIGQUIT (Ctrl+/) will not be caught.
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	// Block until we receive our signal.
 	<-c
 
+	// stop receiving new traffic before draining, the failing readiness
+	// needs a few probe periods to take the instance out of load balancers
+	health.SetShuttingDown()
+	slog.Info("Shutdown started", "delay", config.GetShutdownDelay())
+
+	time.Sleep(config.GetShutdownDelay())
+
 	// Create a deadline to wait for.
 	ctx, cancel := context.WithTimeout(context.Background(), config.GetWait())
 	defer cancel()
@@ -134,6 +334,23 @@ func cmdServer() error {
 	if err != nil {
 		slog.Error("Server forced to shutdown", "error", err)
 	}
+
+	// admin endpoints stay up until the public server is drained
+	if adminSrv != nil {
+		err = adminSrv.Shutdown(ctx)
+
+		if err != nil {
+			slog.Error("Admin server forced to shutdown", "error", err)
+		}
+	}
+
+	// flush spans of the drained requests
+	err = shutdownTracing(ctx)
+
+	if err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
+
 	// Optionally, you could run srv.Shutdown in a goroutine and block on
 	// <-ctx.Done() if your application should wait for other services
 	// to finalize based on context cancellation.
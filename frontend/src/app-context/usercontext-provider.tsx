@@ -161,7 +161,14 @@ export const AppContextProvider: React.FunctionComponent<Props> = (
       .then((response) => response.json())
       .then((data) => {
         if (data.error) {
-          throw new Error(data.error);
+          const message = data.error.message ?? data.error;
+
+          // users quote the request id in support tickets
+          throw new Error(
+            data.error.request_id
+              ? `${message} (request id: ${data.error.request_id})`
+              : message,
+          );
         }
 
         updateData({ version: data });
@@ -7,3 +7,11 @@ export interface Version {
   build_time: string;
   go_version: string;
 }
+
+export interface ApiError {
+  code: string;
+  message: string;
+  details?: unknown;
+  retryable: boolean;
+  request_id?: string;
+}
@@ -6,6 +6,7 @@ package config
 
 import (
 	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -20,61 +21,141 @@ var (
 
 type ConfigBuilder interface {
 	BuildCommandlineFlags(rootCmd *cobra.Command, serverCmd *cobra.Command)
-	SyncConfig()
+	SyncConfig() error
+	Validate() error
 }
 
 type Config interface {
 	GetServerPort() int
+	GetListen() []string
+	GetAdminListen() []string
+	GetPublicMetrics() bool
 	GetDebug() bool
+	GetLogLevel() string
+	GetLogFormat() string
+	GetLogOutput() string
+	GetLogFile() string
+	GetLogMaxSize() int
+	GetLogRotateInterval() time.Duration
+	GetLogMaxBackups() int
+	GetLogMaxAge() time.Duration
+	GetLogCompress() bool
+	GetAccessLogFormat() string
+	GetAccessLogTemplate() string
+	GetAccessLogOutput() string
+	GetAccessLogFile() string
+	GetAccessLogExclude() []string
+	GetAccessLogSampleRate() float64
 	GetWait() time.Duration
+	GetShutdownDelay() time.Duration
 	GetOidcIssuer() string
 	GetOidcAudience() string
+	GetOidcGroupsClaim() string
 	GetLocalStaticPath() string
 	GetKubeCAFile() string
 	GetKubeApiServer() string
+	GetKubeAuthMode() string
+	GetKubeUserPrefix() string
+	GetKubeGroupPrefix() string
+	GetApiMaxBodySize() int64
+	GetApiMaxQuerySize() int
+	GetApiMaxJSONDepth() int
+	GetApiMaxJSONFields() int
+	GetTLSCertFile() string
+	GetTLSKeyFile() string
+	GetTLSClientCAFile() string
+	GetTLSClientAuth() string
+	GetTLSMinVersion() string
+	GetTLSCipherSuites() []string
+	GetTraceEndpoint() string
+	GetTraceSampler() string
+	GetTraceSamplerRatio() float64
+	GetTraceServiceName() string
 	GetVersion() string
 	GetBuildTime() string
 	GetGoVersion() string
 }
 
 type config struct {
-	serverPort      int
-	debug           bool
-	wait            time.Duration
-	rotateTimer     *time.Timer
-	oidcIssuer      string
-	oidcAudience    string
-	localStaticPath string
-	kubeCAFile      string
-	kubeApiServer   string
+	Debug         bool          `config:"debug" scope:"root" short:"d" usage:"Enable debug mode"`
+	LogLevel      string        `config:"logLevel" scope:"root" default:"info" reload:"live" validate:"oneof=debug|info|warn|error" usage:"Log level: debug, info, warn or error, debug mode forces debug"`
+	LogFormat     string        `config:"logFormat" scope:"root" default:"pretty" validate:"oneof=pretty|json|logfmt" usage:"Log format: pretty, json or logfmt"`
+	LogOutput     string        `config:"logOutput" scope:"root" default:"stderr" validate:"oneof=stderr|stdout|file" usage:"Log destination: stderr, stdout or file"`
+	LogFile       string        `config:"logFile" scope:"root" usage:"Log file path when logOutput is file"`
+	LogMaxSize    int           `config:"logMaxSize" scope:"root" validate:"min=0" usage:"Rotate the log file when it reaches this many megabytes (0 disables)"`
+	LogRotate     time.Duration `config:"logRotateInterval" scope:"root" validate:"min=0" usage:"Rotate the log file periodically, e.g. 24h (0 disables)"`
+	LogMaxBackups int           `config:"logMaxBackups" scope:"root" validate:"min=0" usage:"Number of rotated log files to keep (0 keeps all)"`
+	LogMaxAge     time.Duration `config:"logMaxAge" scope:"root" validate:"min=0" usage:"Remove rotated log files older than this, e.g. 720h (0 keeps all)"`
+	LogCompress   bool          `config:"logCompress" scope:"root" usage:"Gzip rotated log files"`
+
+	ServerPort    int           `config:"serverPort" short:"p" default:"8080" validate:"port" usage:"Port to listen on"`
+	Listen        []string      `config:"listen" usage:"Addresses to listen on: host:port, [ipv6]:port or unix:///path.sock?mode=0660 (default is :serverPort)"`
+	AdminListen   []string      `config:"adminListen" usage:"Addresses of the internal admin listener for probes, metrics and debug endpoints (disabled when empty)"`
+	PublicMetrics bool          `config:"publicMetrics" usage:"Serve /metrics on the public listeners when adminListen is empty"`
+	Wait          time.Duration `config:"wait" short:"w" default:"15s" validate:"min=0" usage:"Time to wait before shutting down"`
+	ShutdownDelay time.Duration `config:"shutdownDelay" default:"5s" validate:"min=0" usage:"Time to keep serving with failing readiness after a stop signal, so load balancers stop sending traffic before draining"`
+
+	OidcIssuer      string `config:"oidcIssuer" validate:"url" usage:"OIDC Issuer"`
+	OidcAudience    string `config:"oidcAudience" reload:"live" usage:"OIDC Audience"`
+	OidcGroupsClaim string `config:"oidcGroupsClaim" default:"groups" reload:"live" usage:"OIDC claim holding groups or roles, nested claims are separated by dots (e.g. realm_access.roles)"`
+
+	LocalStaticPath string `config:"localStaticPath" validate:"dir" usage:"Local path to static files"`
+
+	KubeCAFile      string `config:"kubeCAFile" validate:"file" usage:"Kubernetes CA file"`
+	KubeApiServer   string `config:"kubeApiServer" validate:"url" usage:"Kubernetes API server URL, http is only allowed on loopback"`
+	KubeAuthMode    string `config:"kubeAuthMode" default:"impersonate" validate:"oneof=impersonate|token|serviceaccount" usage:"Identity of Kubernetes calls: impersonate (caller via impersonation headers), token (caller's bearer token, the API server must trust oidcIssuer) or serviceaccount"`
+	KubeUserPrefix  string `config:"kubeUserPrefix" usage:"Prefix of impersonated user names, e.g. oidc: to match the API server's --oidc-username-prefix"`
+	KubeGroupPrefix string `config:"kubeGroupPrefix" usage:"Prefix of impersonated groups, e.g. oidc: to match the API server's --oidc-groups-prefix"`
+
+	ApiMaxBodySize   int64 `config:"apiMaxBodySize" default:"1048576" validate:"min=1" reload:"live" usage:"Maximum api request body size in bytes"`
+	ApiMaxQuerySize  int   `config:"apiMaxQuerySize" default:"8192" validate:"min=1" reload:"live" usage:"Maximum api query string size in bytes"`
+	ApiMaxJSONDepth  int   `config:"apiMaxJSONDepth" default:"32" validate:"min=1" reload:"live" usage:"Maximum nesting depth of api request JSON"`
+	ApiMaxJSONFields int   `config:"apiMaxJSONFields" default:"10000" validate:"min=1" reload:"live" usage:"Maximum number of object fields and array elements in api request JSON"`
+
+	TLSCertFile     string   `config:"tlsCertFile" validate:"file" usage:"TLS certificate file, enables TLS"`
+	TLSKeyFile      string   `config:"tlsKeyFile" validate:"file" usage:"TLS private key file"`
+	TLSClientCAFile string   `config:"tlsClientCAFile" validate:"file" usage:"CA file for verifying client certificates, enables mutual TLS"`
+	TLSClientAuth   string   `config:"tlsClientAuth" default:"require" validate:"oneof=require|optional" usage:"Client certificate policy with client CA: require or optional"`
+	TLSMinVersion   string   `config:"tlsMinVersion" default:"1.2" validate:"oneof=1.2|1.3" usage:"Minimum TLS version: 1.2 or 1.3"`
+	TLSCipherSuites []string `config:"tlsCipherSuites" usage:"Allowed TLS 1.2 cipher suites (default is Go's secure set)"`
+
+	TraceEndpoint    string  `config:"traceEndpoint" validate:"url" usage:"OTLP/HTTP collector URL for trace export, e.g. http://localhost:4318 (disabled when empty)"`
+	TraceSampler     string  `config:"traceSampler" default:"parentbased_always_on" validate:"oneof=always_on|always_off|traceidratio|parentbased_always_on|parentbased_always_off|parentbased_traceidratio" usage:"Trace sampler: always_on, always_off, traceidratio or their parentbased_ variants"`
+	TraceRatio       float64 `config:"traceSamplerRatio" default:"1" validate:"min=0,max=1" usage:"Sampling ratio of the traceidratio sampler"`
+	TraceServiceName string  `config:"traceServiceName" default:"go_react_mui" usage:"Service name reported with traces"`
+
+	AccessLogFormat  string   `config:"accessLogFormat" default:"combined" validate:"oneof=common|combined|json|custom" usage:"Access log format: common, combined, json or custom"`
+	AccessLogTpl     string   `config:"accessLogTemplate" usage:"Go template of the custom access log format, e.g. {{.RemoteHost}} {{.Status}} {{.Duration}}"`
+	AccessLogOutput  string   `config:"accessLogOutput" default:"app" validate:"oneof=app|off|stderr|stdout|file" usage:"Access log destination: app (application log), off, stderr, stdout or file"`
+	AccessLogFile    string   `config:"accessLogFile" usage:"Access log file path when accessLogOutput is file, rotated like the application log"`
+	AccessLogExclude []string `config:"accessLogExclude" usage:"Request path patterns not access logged, e.g. /healthz,/readyz"`
+	AccessLogSample  float64  `config:"accessLogSampleRate" default:"1" validate:"min=0,max=1" usage:"Share of requests access logged between 0 and 1, server errors are always logged"`
 }
 
 var (
+	// _config is the builder, flags are bound to its fields
 	_config *config = nil
+
+	// current is the synced config, replaced as a whole on reload
+	current atomic.Pointer[config]
 )
 
 func getConfigSingleton() *config {
 	if _config == nil {
-
-		rt := time.NewTimer(1 * time.Hour)
-
-		go func() {
-			for {
-				<-rt.C
-
-				rt.Reset(1 * time.Hour)
-			}
-		}()
-
-		_config = &config{
-			rotateTimer: rt,
-		}
+		_config = &config{}
 	}
 
 	return _config
 }
 
+// GetConfig returns the current settings. Settings which change at runtime
+// are replaced atomically, callers should not keep the returned value for
+// them.
 func GetConfig() Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+
 	return getConfigSingleton()
 }
 
@@ -82,122 +163,237 @@ func GetConfigBuilder() ConfigBuilder {
 	return getConfigSingleton()
 }
 
+// BuildCommandlineFlags generates a flag, environment variables and the
+// default of every field of the config schema.
 func (c *config) BuildCommandlineFlags(rootCmd *cobra.Command, serverCmd *cobra.Command) {
-	rootCmd.PersistentFlags().BoolVarP(&c.debug, "debug", "d", false, "Enable debug mode")
-
-	err := viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
+	for _, o := range c.options() {
+		flags := serverCmd.Flags()
 
-	if err != nil {
-		slog.Error("Error binding debug flag", "error", err)
-	}
+		if o.scope == scopeRoot {
+			flags = rootCmd.PersistentFlags()
+		}
 
-	viper.SetDefault("debug", false)
+		o.addFlag(flags)
 
-	serverCmd.Flags().IntVarP(&c.serverPort, "serverPort", "p", 0, "Port to listen on")
-	err = viper.BindPFlag("serverPort", serverCmd.Flags().Lookup("serverPort"))
+		err := viper.BindPFlag(o.key, flags.Lookup(o.key))
 
-	if err != nil {
-		slog.Error("Error binding serverPort flag", "error", err)
-	}
+		if err != nil {
+			slog.Error("Error binding flag", "flag", o.key, "error", err)
+		}
 
-	viper.SetDefault("serverPort", 8080)
+		err = viper.BindEnv(append([]string{o.key}, o.envNames()...)...)
 
-	serverCmd.Flags().DurationVarP(&c.wait, "wait", "w", 0, "Time to wait before shutting down")
-	err = viper.BindPFlag("wait", serverCmd.Flags().Lookup("wait"))
+		if err != nil {
+			slog.Error("Error binding environment variable", "flag", o.key, "error", err)
+		}
 
-	if err != nil {
-		slog.Error("Error binding wait flag", "error", err)
+		viper.SetDefault(o.key, o.defaultValue)
 	}
+}
 
-	viper.SetDefault("wait", 15*time.Second)
+// SyncConfig loads the settings from viper and validates the ones of every
+// command, so a server setting can not break commands such as version. The
+// error lists every problem found.
+func (c *config) SyncConfig() error {
+	next := &config{}
 
-	serverCmd.Flags().StringVarP(&c.oidcIssuer, "oidcIssuer", "", "", "OIDC Issuer")
-	err = viper.BindPFlag("oidcIssuer", serverCmd.Flags().Lookup("oidcIssuer"))
+	for _, o := range next.options() {
+		o.sync()
+	}
 
-	if err != nil {
-		slog.Error("Error binding oidcIssuer flag", "error", err)
+	if err := next.validate(false); err != nil {
+		return err
 	}
 
-	viper.SetDefault("oidcIssuer", "")
+	current.Store(next)
 
-	serverCmd.Flags().StringVarP(&c.oidcAudience, "oidcAudience", "", "", "OIDC Audience")
-	err = viper.BindPFlag("oidcAudience", serverCmd.Flags().Lookup("oidcAudience"))
+	return nil
+}
 
-	if err != nil {
-		slog.Error("Error binding oidcAudience flag", "error", err)
-	}
+// Validate checks every setting of the synced config, including the server
+// ones. The server command calls it before starting.
+func (c *config) Validate() error {
+	return current.Load().validate(true)
+}
+
+func (c *config) GetServerPort() int {
+	return c.ServerPort
+}
 
-	viper.SetDefault("oidcAudience", "")
+func (c *config) GetListen() []string {
+	return c.Listen
+}
 
-	serverCmd.Flags().StringVarP(&c.localStaticPath, "localStaticPath", "", "", "Local path to static files")
-	err = viper.BindPFlag("localStaticPath", serverCmd.Flags().Lookup("localStaticPath"))
+func (c *config) GetAdminListen() []string {
+	return c.AdminListen
+}
 
-	if err != nil {
-		slog.Error("Error binding localStaticPath flag", "error", err)
-	}
+func (c *config) GetPublicMetrics() bool {
+	return c.PublicMetrics
+}
 
-	viper.SetDefault("localStaticPath", "")
+func (c *config) GetDebug() bool {
+	return c.Debug
+}
 
-	serverCmd.Flags().StringVarP(&c.kubeCAFile, "kubeCAFile", "", "", "Kubernetes CA file")
-	err = viper.BindPFlag("kubeCAFile", serverCmd.Flags().Lookup("kubeCAFile"))
+func (c *config) GetLogLevel() string {
+	return c.LogLevel
+}
 
-	if err != nil {
-		slog.Error("Error binding kubeCAFile flag", "error", err)
-	}
+func (c *config) GetLogFormat() string {
+	return c.LogFormat
+}
 
-	viper.SetDefault("kubeCAFile", "")
+func (c *config) GetLogOutput() string {
+	return c.LogOutput
+}
 
-	serverCmd.Flags().StringVarP(&c.kubeApiServer, "kubeApiServer", "", "", "Kubernetes API server")
-	err = viper.BindPFlag("kubeApiServer", serverCmd.Flags().Lookup("kubeApiServer"))
+func (c *config) GetLogFile() string {
+	return c.LogFile
+}
 
-	if err != nil {
-		slog.Error("Error binding kubeApiServer flag", "error", err)
-	}
+func (c *config) GetLogMaxSize() int {
+	return c.LogMaxSize
+}
 
-	viper.SetDefault("kubeApiServer", "")
+func (c *config) GetLogRotateInterval() time.Duration {
+	return c.LogRotate
 }
 
-func (c *config) SyncConfig() {
-	c.debug = viper.GetBool("debug")
-	c.serverPort = viper.GetInt("serverPort")
-	c.wait = viper.GetDuration("wait")
-	c.oidcIssuer = viper.GetString("oidcIssuer")
-	c.oidcAudience = viper.GetString("oidcAudience")
-	c.localStaticPath = viper.GetString("localStaticPath")
-	c.kubeCAFile = viper.GetString("kubeCAFile")
-	c.kubeApiServer = viper.GetString("kubeApiServer")
+func (c *config) GetLogMaxBackups() int {
+	return c.LogMaxBackups
 }
 
-func (c *config) GetServerPort() int {
-	return c.serverPort
+func (c *config) GetLogMaxAge() time.Duration {
+	return c.LogMaxAge
 }
 
-func (c *config) GetDebug() bool {
-	return c.debug
+func (c *config) GetLogCompress() bool {
+	return c.LogCompress
 }
 
 func (c *config) GetWait() time.Duration {
-	return c.wait
+	return c.Wait
+}
+
+func (c *config) GetShutdownDelay() time.Duration {
+	return c.ShutdownDelay
 }
 
 func (c *config) GetOidcIssuer() string {
-	return c.oidcIssuer
+	return c.OidcIssuer
 }
 
 func (c *config) GetOidcAudience() string {
-	return c.oidcAudience
+	return c.OidcAudience
+}
+
+func (c *config) GetOidcGroupsClaim() string {
+	return c.OidcGroupsClaim
 }
 
 func (c *config) GetLocalStaticPath() string {
-	return c.localStaticPath
+	return c.LocalStaticPath
 }
 
 func (c *config) GetKubeCAFile() string {
-	return c.kubeCAFile
+	return c.KubeCAFile
 }
 
 func (c *config) GetKubeApiServer() string {
-	return c.kubeApiServer
+	return c.KubeApiServer
+}
+
+func (c *config) GetKubeAuthMode() string {
+	return c.KubeAuthMode
+}
+
+func (c *config) GetKubeUserPrefix() string {
+	return c.KubeUserPrefix
+}
+
+func (c *config) GetKubeGroupPrefix() string {
+	return c.KubeGroupPrefix
+}
+
+func (c *config) GetApiMaxBodySize() int64 {
+	return c.ApiMaxBodySize
+}
+
+func (c *config) GetApiMaxQuerySize() int {
+	return c.ApiMaxQuerySize
+}
+
+func (c *config) GetApiMaxJSONDepth() int {
+	return c.ApiMaxJSONDepth
+}
+
+func (c *config) GetApiMaxJSONFields() int {
+	return c.ApiMaxJSONFields
+}
+
+func (c *config) GetTLSCertFile() string {
+	return c.TLSCertFile
+}
+
+func (c *config) GetTLSKeyFile() string {
+	return c.TLSKeyFile
+}
+
+func (c *config) GetTLSClientCAFile() string {
+	return c.TLSClientCAFile
+}
+
+func (c *config) GetTLSClientAuth() string {
+	return c.TLSClientAuth
+}
+
+func (c *config) GetTLSMinVersion() string {
+	return c.TLSMinVersion
+}
+
+func (c *config) GetTLSCipherSuites() []string {
+	return c.TLSCipherSuites
+}
+
+func (c *config) GetTraceEndpoint() string {
+	return c.TraceEndpoint
+}
+
+func (c *config) GetTraceSampler() string {
+	return c.TraceSampler
+}
+
+func (c *config) GetTraceSamplerRatio() float64 {
+	return c.TraceRatio
+}
+
+func (c *config) GetTraceServiceName() string {
+	return c.TraceServiceName
+}
+
+func (c *config) GetAccessLogFormat() string {
+	return c.AccessLogFormat
+}
+
+func (c *config) GetAccessLogTemplate() string {
+	return c.AccessLogTpl
+}
+
+func (c *config) GetAccessLogOutput() string {
+	return c.AccessLogOutput
+}
+
+func (c *config) GetAccessLogFile() string {
+	return c.AccessLogFile
+}
+
+func (c *config) GetAccessLogExclude() []string {
+	return c.AccessLogExclude
+}
+
+func (c *config) GetAccessLogSampleRate() float64 {
+	return c.AccessLogSample
 }
 
 func (c *config) GetVersion() string {
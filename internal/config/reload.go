@@ -0,0 +1,188 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Subscriber is notified after a reload applied settings, changed holds
+// their keys.
+type Subscriber func(c Config, changed []string)
+
+// reloadDelay is the quiet time after a config file event before reloading.
+const reloadDelay = 200 * time.Millisecond
+
+var (
+	// reloadM serializes reloads from the file watcher and signals, viper
+	// is not safe for concurrent use and is only touched under it once the
+	// server runs
+	reloadM sync.Mutex
+
+	subscribersM sync.Mutex
+	subscribers  []Subscriber
+)
+
+// Subscribe registers fn to be called after every reload which changed a
+// live setting.
+func Subscribe(fn Subscriber) {
+	subscribersM.Lock()
+	defer subscribersM.Unlock()
+
+	subscribers = append(subscribers, fn)
+}
+
+// WatchConfigFile reloads the config file whenever it changes. The directory
+// is watched, so editors and ConfigMap mounts which replace the file are seen
+// as well. Nothing is watched when no config file is used.
+func WatchConfigFile() error {
+	file := viper.ConfigFileUsed()
+
+	if file == "" {
+		return nil
+	}
+
+	file = filepath.Clean(file)
+
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+
+	go watchConfigFile(watcher, file)
+
+	return nil
+}
+
+func watchConfigFile(watcher *fsnotify.Watcher, file string) {
+	defer watcher.Close()
+
+	// symlinks are followed to see a ConfigMap swapping its data directory
+	realFile, _ := filepath.EvalSymlinks(file)
+
+	// editors writing in place truncate the file first, reload once the
+	// writes settled instead of reading it half written
+	settle := time.NewTimer(reloadDelay)
+	settle.Stop()
+
+	for {
+		select {
+		case e, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			target, _ := filepath.EvalSymlinks(file)
+
+			written := filepath.Clean(e.Name) == file && e.Op&(fsnotify.Write|fsnotify.Create) != 0
+
+			if written || (target != "" && target != realFile) {
+				realFile = target
+				settle.Reset(reloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			slog.Error("Error watching config file", "error", err)
+		case <-settle.C:
+			slog.Info("Config file changed", "file", file)
+
+			if err := Reload(); err != nil {
+				slog.Error("Error reloading config", "error", err)
+			}
+		}
+	}
+}
+
+// Reload reads the config file again and applies the settings tagged as live
+// at once. Changes of other settings are logged as needing a restart and the
+// running values are kept. An invalid config is rejected as a whole.
+func Reload() error {
+	reloadM.Lock()
+	defer reloadM.Unlock()
+
+	if viper.ConfigFileUsed() != "" {
+		if err := viper.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	prev := current.Load()
+
+	if prev == nil {
+		return fmt.Errorf("config is not synced")
+	}
+
+	next := &config{}
+
+	for _, o := range next.options() {
+		o.sync()
+	}
+
+	if err := next.validate(true); err != nil {
+		return err
+	}
+
+	// start from the running values and take over the live ones
+	merged := *prev
+
+	var applied, restart []string
+
+	nextOptions := next.options()
+
+	for i, o := range merged.options() {
+		value := nextOptions[i].field
+
+		if reflect.DeepEqual(o.field.Interface(), value.Interface()) {
+			continue
+		}
+
+		if o.live {
+			o.field.Set(value)
+			applied = append(applied, o.key)
+		} else {
+			restart = append(restart, o.key)
+		}
+	}
+
+	if len(restart) > 0 {
+		slog.Warn("Config changes need a restart", "settings", restart)
+	}
+
+	if len(applied) == 0 {
+		slog.Info("Config reloaded, no settings applied")
+		return nil
+	}
+
+	current.Store(&merged)
+
+	slog.Info("Config reloaded", "applied", applied)
+
+	subscribersM.Lock()
+	subs := append([]Subscriber(nil), subscribers...)
+	subscribersM.Unlock()
+
+	for _, fn := range subs {
+		fn(&merged, applied)
+	}
+
+	return nil
+}
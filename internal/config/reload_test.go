@@ -0,0 +1,185 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reloadCall is one notification of a subscriber.
+type reloadCall struct {
+	logLevel string
+	changed  []string
+}
+
+// setupReloadTest syncs the config from a config file with the content and
+// subscribes to reloads. The previous config, subscribers and logger are
+// restored when the test ends.
+func setupReloadTest(t *testing.T, content string) (string, *[]reloadCall, *bytes.Buffer) {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, file, content)
+
+	prevConfig := current.Load()
+
+	subscribersM.Lock()
+	prevSubscribers := subscribers
+	subscribers = nil
+	subscribersM.Unlock()
+
+	logs := &bytes.Buffer{}
+	prevLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(logs, nil)))
+
+	t.Cleanup(func() {
+		slog.SetDefault(prevLogger)
+
+		subscribersM.Lock()
+		subscribers = prevSubscribers
+		subscribersM.Unlock()
+
+		current.Store(prevConfig)
+	})
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	GetConfigBuilder().BuildCommandlineFlags(&cobra.Command{}, &cobra.Command{})
+	viper.SetConfigFile(file)
+
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+
+	if err := GetConfigBuilder().SyncConfig(); err != nil {
+		t.Fatalf("sync config: %v", err)
+	}
+
+	calls := new([]reloadCall)
+
+	Subscribe(func(c Config, changed []string) {
+		*calls = append(*calls, reloadCall{logLevel: c.GetLogLevel(), changed: changed})
+	})
+
+	return file, calls, logs
+}
+
+func writeConfigFile(t *testing.T, file string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantErr   bool
+		logLevel  string
+		port      int
+		calls     []reloadCall
+		restarted bool
+	}{
+		{
+			name:     "live change",
+			content:  "logLevel: debug\nserverPort: 8081\n",
+			logLevel: "debug",
+			port:     8081,
+			calls:    []reloadCall{{logLevel: "debug", changed: []string{"logLevel"}}},
+		},
+		{
+			name:      "restart change",
+			content:   "logLevel: warn\nserverPort: 9090\n",
+			logLevel:  "warn",
+			port:      8081,
+			restarted: true,
+		},
+		{
+			name:      "live and restart changes",
+			content:   "logLevel: error\nserverPort: 9090\n",
+			logLevel:  "error",
+			port:      8081,
+			calls:     []reloadCall{{logLevel: "error", changed: []string{"logLevel"}}},
+			restarted: true,
+		},
+		{
+			name:     "no change",
+			content:  "logLevel: warn\nserverPort: 8081\n",
+			logLevel: "warn",
+			port:     8081,
+		},
+		{
+			name:     "invalid value",
+			content:  "logLevel: error\nserverPort: 8081\nlogFormat: bogus\n",
+			wantErr:  true,
+			logLevel: "warn",
+			port:     8081,
+		},
+		{
+			name:     "malformed file",
+			content:  "logLevel: [error\n",
+			wantErr:  true,
+			logLevel: "warn",
+			port:     8081,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, calls, logs := setupReloadTest(t, "logLevel: warn\nserverPort: 8081\n")
+
+			writeConfigFile(t, file, tt.content)
+
+			err := Reload()
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			c := GetConfig()
+
+			if c.GetLogLevel() != tt.logLevel || c.GetServerPort() != tt.port || c.GetLogFormat() != "pretty" {
+				t.Errorf("config = logLevel %s, serverPort %d, logFormat %s, want %s, %d, pretty",
+					c.GetLogLevel(), c.GetServerPort(), c.GetLogFormat(), tt.logLevel, tt.port)
+			}
+
+			if !reflect.DeepEqual(*calls, tt.calls) {
+				t.Errorf("subscribers got %+v, want %+v", *calls, tt.calls)
+			}
+
+			restarted := strings.Contains(logs.String(), "Config changes need a restart") &&
+				strings.Contains(logs.String(), "serverPort")
+
+			if restarted != tt.restarted {
+				t.Errorf("restart reported = %v, want %v: %s", restarted, tt.restarted, logs)
+			}
+		})
+	}
+}
+
+func TestReloadWithoutSync(t *testing.T) {
+	prev := current.Load()
+	current.Store(nil)
+	t.Cleanup(func() { current.Store(prev) })
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	if err := Reload(); err == nil {
+		t.Error("Reload() succeeded before the config was synced")
+	}
+}
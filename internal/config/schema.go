@@ -0,0 +1,231 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// The config struct is the schema of the settings. Each field tagged with
+// config is a setting:
+//
+//	config   key of the setting, also the flag name
+//	scope    root for flags of every command, server flags otherwise
+//	short    one letter flag shorthand
+//	default  default value, slices are comma separated
+//	validate comma separated rules, see option.check
+//	reload   live when a reload applies the setting, restart otherwise
+//	usage    flag help
+//
+// Settings are read from flags, environment variables and the config file.
+// The environment variable of serverPort is SERVER_PORT, SERVERPORT is
+// accepted as well.
+
+const scopeRoot = "root"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// option is a setting of the schema bound to its config field.
+type option struct {
+	key          string
+	scope        string
+	short        string
+	usage        string
+	rules        []string
+	live         bool
+	defaultValue interface{}
+	field        reflect.Value
+}
+
+// options returns the settings of the schema in declaration order. A broken
+// tag is a programming error and panics.
+func (c *config) options() []option {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	var options []option
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		key := f.Tag.Get("config")
+		if key == "" {
+			continue
+		}
+
+		o := option{
+			key:   key,
+			scope: f.Tag.Get("scope"),
+			short: f.Tag.Get("short"),
+			usage: f.Tag.Get("usage"),
+			live:  f.Tag.Get("reload") == "live",
+			field: v.Field(i),
+		}
+
+		if rules := f.Tag.Get("validate"); rules != "" {
+			o.rules = strings.Split(rules, ",")
+		}
+
+		def, err := parseDefault(f.Type, f.Tag.Get("default"))
+
+		if err != nil {
+			panic(fmt.Sprintf("config: invalid default of %s: %v", key, err))
+		}
+
+		o.defaultValue = def
+
+		options = append(options, o)
+	}
+
+	return options
+}
+
+func parseDefault(t reflect.Type, s string) (interface{}, error) {
+	if t == durationType {
+		if s == "" {
+			return time.Duration(0), nil
+		}
+
+		return time.ParseDuration(s)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		if s == "" {
+			return false, nil
+		}
+
+		return strconv.ParseBool(s)
+	case reflect.Int:
+		if s == "" {
+			return 0, nil
+		}
+
+		return strconv.Atoi(s)
+	case reflect.Int64:
+		if s == "" {
+			return int64(0), nil
+		}
+
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Float64:
+		if s == "" {
+			return float64(0), nil
+		}
+
+		return strconv.ParseFloat(s, 64)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			if s == "" {
+				return []string{}, nil
+			}
+
+			return strings.Split(s, ","), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// addFlag defines the flag of the option, its default shows in the help.
+func (o option) addFlag(flags *pflag.FlagSet) {
+	ptr := o.field.Addr().Interface()
+
+	switch p := ptr.(type) {
+	case *string:
+		flags.StringVarP(p, o.key, o.short, o.defaultValue.(string), o.usage)
+	case *bool:
+		flags.BoolVarP(p, o.key, o.short, o.defaultValue.(bool), o.usage)
+	case *int:
+		flags.IntVarP(p, o.key, o.short, o.defaultValue.(int), o.usage)
+	case *int64:
+		flags.Int64VarP(p, o.key, o.short, o.defaultValue.(int64), o.usage)
+	case *float64:
+		flags.Float64VarP(p, o.key, o.short, o.defaultValue.(float64), o.usage)
+	case *time.Duration:
+		flags.DurationVarP(p, o.key, o.short, o.defaultValue.(time.Duration), o.usage)
+	case *[]string:
+		flags.StringSliceVarP(p, o.key, o.short, o.defaultValue.([]string), o.usage)
+	default:
+		panic(fmt.Sprintf("config: unsupported type of %s", o.key))
+	}
+}
+
+// envNames returns the environment variables of the option, the snake case
+// name first and the upper case key viper's AutomaticEnv used before.
+func (o option) envNames() []string {
+	var b strings.Builder
+
+	runes := []rune(o.key)
+
+	for i, r := range runes {
+		// word boundaries: fooBar, fooBAR and the end of an acronym, JSONDepth
+		if i > 0 && unicode.IsUpper(r) &&
+			(!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+
+		b.WriteRune(unicode.ToUpper(r))
+	}
+
+	snake, upper := b.String(), strings.ToUpper(o.key)
+
+	if snake == upper {
+		return []string{snake}
+	}
+
+	return []string{snake, upper}
+}
+
+// sync copies the value viper resolved into the config field.
+func (o option) sync() {
+	var v interface{}
+
+	switch o.field.Interface().(type) {
+	case string:
+		v = viper.GetString(o.key)
+	case bool:
+		v = viper.GetBool(o.key)
+	case int:
+		v = viper.GetInt(o.key)
+	case int64:
+		v = viper.GetInt64(o.key)
+	case float64:
+		v = viper.GetFloat64(o.key)
+	case time.Duration:
+		v = viper.GetDuration(o.key)
+	case []string:
+		// environment variables hold comma separated lists
+		if s, ok := viper.Get(o.key).(string); ok {
+			v = splitList(s)
+		} else {
+			v = viper.GetStringSlice(o.key)
+		}
+	}
+
+	o.field.Set(reflect.ValueOf(v))
+}
+
+func splitList(s string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,34 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvNames(t *testing.T) {
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"debug", []string{"DEBUG"}},
+		{"serverPort", []string{"SERVER_PORT", "SERVERPORT"}},
+		{"logRotateInterval", []string{"LOG_ROTATE_INTERVAL", "LOGROTATEINTERVAL"}},
+		{"kubeCAFile", []string{"KUBE_CA_FILE", "KUBECAFILE"}},
+		{"apiMaxJSONDepth", []string{"API_MAX_JSON_DEPTH", "APIMAXJSONDEPTH"}},
+		{"tlsClientCAFile", []string{"TLS_CLIENT_CA_FILE", "TLSCLIENTCAFILE"}},
+		{"oidcIssuer", []string{"OIDC_ISSUER", "OIDCISSUER"}},
+		{"endsWithID", []string{"ENDS_WITH_ID", "ENDSWITHID"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := (option{key: tt.key}).envNames(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("envNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ValidationError lists the problems of an invalid configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// validate checks the settings against their rules and each other, so a
+// misconfiguration stops the start instead of failing requests later. Server
+// settings and unknown keys are only checked when server is set.
+func (c *config) validate(server bool) error {
+	var problems []string
+
+	options := c.options()
+
+	checked := map[string]bool{}
+
+	for _, o := range options {
+		if !server && o.scope != scopeRoot {
+			continue
+		}
+
+		checked[o.key] = true
+
+		for _, rule := range o.rules {
+			if problem := o.check(rule); problem != "" {
+				problems = append(problems, o.key+": "+problem)
+			}
+		}
+	}
+
+	problems = append(problems, c.crossFieldProblems(checked)...)
+
+	if server {
+		problems = append(problems, unknownKeys(options)...)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// check returns the problem of the option with the rule, empty when valid.
+// Rules of files and URLs do not apply to empty values.
+func (o option) check(rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	switch name {
+	case "port":
+		port := o.field.Int()
+
+		if port < 1 || port > 65535 {
+			return fmt.Sprintf("must be between 1 and 65535, got %d", port)
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+
+		if err != nil {
+			panic(fmt.Sprintf("config: invalid %s rule of %s", name, o.key))
+		}
+
+		value := o.number()
+
+		if name == "min" && value < limit {
+			return fmt.Sprintf("must be at least %s, got %v", arg, o.field.Interface())
+		}
+
+		if name == "max" && value > limit {
+			return fmt.Sprintf("must be at most %s, got %v", arg, o.field.Interface())
+		}
+	case "oneof":
+		allowed := strings.Split(arg, "|")
+
+		if !slices.Contains(allowed, o.field.String()) {
+			return fmt.Sprintf("must be one of %s, got %q", strings.Join(allowed, ", "), o.field.String())
+		}
+	case "file":
+		if path := o.field.String(); path != "" {
+			return checkReadable(path, false)
+		}
+	case "dir":
+		if path := o.field.String(); path != "" {
+			return checkReadable(path, true)
+		}
+	case "url":
+		if s := o.field.String(); s != "" {
+			u, err := url.Parse(s)
+
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Sprintf("must be an http or https URL, got %q", s)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("config: unknown rule %s of %s", name, o.key))
+	}
+
+	return ""
+}
+
+func (o option) number() float64 {
+	switch v := o.field.Interface().(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case time.Duration:
+		return float64(v)
+	}
+
+	panic(fmt.Sprintf("config: %s is not a number", o.key))
+}
+
+func checkReadable(path string, dir bool) string {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return fmt.Sprintf("can not be read: %v", err)
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+
+	if err != nil {
+		return fmt.Sprintf("can not be read: %v", err)
+	}
+
+	if dir && !fi.IsDir() {
+		return fmt.Sprintf("%s is not a directory", path)
+	}
+
+	if !dir && fi.IsDir() {
+		return fmt.Sprintf("%s is a directory", path)
+	}
+
+	return ""
+}
+
+// crossFieldProblems checks settings which depend on each other, a rule
+// applies when its required key is checked.
+func (c *config) crossFieldProblems(checked map[string]bool) []string {
+	var problems []string
+
+	require := func(key string, missing bool, reason string) {
+		if missing && checked[key] {
+			problems = append(problems, key+": required when "+reason)
+		}
+	}
+
+	require("oidcAudience", c.OidcIssuer != "" && c.OidcAudience == "", "oidcIssuer is set")
+	require("oidcIssuer", c.OidcAudience != "" && c.OidcIssuer == "", "oidcAudience is set")
+	require("tlsKeyFile", c.TLSCertFile != "" && c.TLSKeyFile == "", "tlsCertFile is set")
+	require("tlsCertFile", c.TLSKeyFile != "" && c.TLSCertFile == "", "tlsKeyFile is set")
+	require("tlsCertFile", c.TLSClientCAFile != "" && c.TLSCertFile == "", "tlsClientCAFile is set")
+	require("logFile", c.LogOutput == "file" && c.LogFile == "", "logOutput is file")
+	require("accessLogFile", c.AccessLogOutput == "file" && c.AccessLogFile == "", "accessLogOutput is file")
+	require("accessLogTemplate", c.AccessLogFormat == "custom" && c.AccessLogTpl == "", "accessLogFormat is custom")
+	require("oidcIssuer", c.KubeAuthMode == "token" && c.OidcIssuer == "", "kubeAuthMode is token")
+
+	return problems
+}
+
+// unknownKeys reports settings of the config file which are not in the
+// schema, mostly typos which would silently fall back to defaults.
+func unknownKeys(options []option) []string {
+	known := map[string]bool{"config": true}
+
+	for _, o := range options {
+		known[strings.ToLower(o.key)] = true
+	}
+
+	var problems []string
+
+	for _, key := range viper.AllKeys() {
+		if !known[key] {
+			problems = append(problems, key+": unknown setting")
+		}
+	}
+
+	slices.Sort(problems)
+
+	return problems
+}
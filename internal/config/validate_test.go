@@ -0,0 +1,133 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// loadTestConfig builds a config of the defaults and the settings the way
+// SyncConfig does, without validating it.
+func loadTestConfig(t *testing.T, settings map[string]interface{}) *config {
+	t.Helper()
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	GetConfigBuilder().BuildCommandlineFlags(&cobra.Command{}, &cobra.Command{})
+
+	for key, value := range settings {
+		viper.Set(key, value)
+	}
+
+	c := &config{}
+
+	for _, o := range c.options() {
+		o.sync()
+	}
+
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+
+	if err := os.WriteFile(file, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		// problems of the root scope settings, checked by every command
+		root []string
+		// problems the server command adds
+		server []string
+	}{
+		{name: "defaults"},
+		{
+			name:     "valid",
+			settings: map[string]interface{}{"serverPort": 443, "oidcIssuer": "https://idp.example", "oidcAudience": "app", "tlsCertFile": file, "tlsKeyFile": file},
+		},
+		{
+			name:     "port",
+			settings: map[string]interface{}{"serverPort": 70000},
+			server:   []string{"serverPort: must be between 1 and 65535, got 70000"},
+		},
+		{
+			name:     "oneof",
+			settings: map[string]interface{}{"logFormat": "xml", "kubeAuthMode": "basic"},
+			root:     []string{`logFormat: must be one of pretty, json, logfmt, got "xml"`},
+			server:   []string{`kubeAuthMode: must be one of impersonate, token, serviceaccount, got "basic"`},
+		},
+		{
+			name:     "min",
+			settings: map[string]interface{}{"logMaxBackups": -1},
+			root:     []string{"logMaxBackups: must be at least 0, got -1"},
+		},
+		{
+			name:     "url",
+			settings: map[string]interface{}{"oidcIssuer": "idp.example", "oidcAudience": "app"},
+			server:   []string{`oidcIssuer: must be an http or https URL, got "idp.example"`},
+		},
+		{
+			name:     "file",
+			settings: map[string]interface{}{"tlsCertFile": dir, "tlsKeyFile": file},
+			server:   []string{"tlsCertFile: " + dir + " is a directory"},
+		},
+		{
+			name:     "root cross field",
+			settings: map[string]interface{}{"logOutput": "file"},
+			root:     []string{"logFile: required when logOutput is file"},
+		},
+		{
+			name:     "server cross field",
+			settings: map[string]interface{}{"oidcAudience": "app", "kubeAuthMode": "token"},
+			server:   []string{"oidcIssuer: required when oidcAudience is set", "oidcIssuer: required when kubeAuthMode is token"},
+		},
+		{
+			name:     "unknown key",
+			settings: map[string]interface{}{"serverport2": 1},
+			server:   []string{"serverport2: unknown setting"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := loadTestConfig(t, tt.settings)
+
+			checkProblems(t, "root", c.validate(false), tt.root)
+			checkProblems(t, "server", c.validate(true), append(append([]string{}, tt.root...), tt.server...))
+		})
+	}
+}
+
+func checkProblems(t *testing.T, scope string, err error, want []string) {
+	t.Helper()
+
+	var got []string
+
+	if err != nil {
+		var ve *ValidationError
+
+		if !errors.As(err, &ve) {
+			t.Fatalf("%s: validate() error = %v, want a ValidationError", scope, err)
+		}
+
+		got = ve.Problems
+	}
+
+	if !reflect.DeepEqual(got, want) && (len(got) != 0 || len(want) != 0) {
+		t.Errorf("%s: problems = %q, want %q", scope, got, want)
+	}
+}
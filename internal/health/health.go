@@ -0,0 +1,225 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultCheckTimeout = 5 * time.Second
+
+// CheckFunc reports a problem of a subsystem by returning an error.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// CheckResult is the outcome of a single check.
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report is the aggregated outcome of a probe.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusShuttingDown = "shutting_down"
+)
+
+var (
+	checksM         sync.RWMutex
+	livenessChecks  = map[string]check{}
+	readinessChecks = map[string]check{}
+	shuttingDown    atomic.Bool
+)
+
+// RegisterLiveness registers a check of /healthz. Liveness checks should
+// only fail when restarting the process helps.
+func RegisterLiveness(name string, timeout time.Duration, fn CheckFunc) {
+	register(livenessChecks, name, timeout, fn)
+}
+
+// RegisterReadiness registers a check of /readyz, e.g. reachability of a
+// dependency needed to serve requests.
+func RegisterReadiness(name string, timeout time.Duration, fn CheckFunc) {
+	register(readinessChecks, name, timeout, fn)
+}
+
+func register(checks map[string]check, name string, timeout time.Duration, fn CheckFunc) {
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+
+	checksM.Lock()
+	defer checksM.Unlock()
+
+	if _, ok := checks[name]; ok {
+		slog.Warn("Health check replaced", "name", name)
+	}
+
+	checks[name] = check{name: name, timeout: timeout, fn: fn}
+}
+
+// SetShuttingDown makes readiness fail from now on, so load balancers stop
+// sending new requests while in flight ones are drained.
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// Liveness runs the liveness checks.
+func Liveness(ctx context.Context) Report {
+	return run(ctx, livenessChecks)
+}
+
+// Readiness runs the readiness checks, it fails without running them when
+// shutdown began.
+func Readiness(ctx context.Context) Report {
+	if shuttingDown.Load() {
+		return Report{Status: StatusShuttingDown, Checks: map[string]CheckResult{}}
+	}
+
+	return run(ctx, readinessChecks)
+}
+
+func run(ctx context.Context, checks map[string]check) Report {
+	checksM.RLock()
+	list := make([]check, 0, len(checks))
+	for _, c := range checks {
+		list = append(list, c)
+	}
+	checksM.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+
+	results := make([]CheckResult, len(list))
+
+	var wg sync.WaitGroup
+
+	for i, c := range list {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}()
+	}
+
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(list))}
+
+	for i, c := range list {
+		report.Checks[c.name] = results[i]
+
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+
+	return report
+}
+
+func runCheck(ctx context.Context, c check) (result CheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	done := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- errors.New("check panicked")
+			}
+		}()
+
+		done <- c.fn(ctx)
+	}()
+
+	var err error
+
+	// a check ignoring its context must not block the probe
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		// errors name internal addresses, probes may be public so they only
+		// get the gist and the details go to the log
+		slog.WarnContext(ctx, "Health check failed", "name", c.name, "error", err)
+		result.Status = StatusFailing
+		result.Error = "check failed"
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "check timed out"
+		}
+
+		return result
+	}
+
+	result.Status = StatusOK
+
+	return result
+}
+
+// LivenessHandler serves /healthz.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, r, Liveness(r.Context()))
+}
+
+// ReadinessHandler serves /readyz.
+func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, r, Readiness(r.Context()))
+}
+
+func writeReport(w http.ResponseWriter, r *http.Request, report Report) {
+	buf, err := json.Marshal(report)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error marshaling health report", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	if report.Status == StatusOK {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_, err = w.Write(buf)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error writing response", "error", err)
+	}
+}
@@ -0,0 +1,343 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package kube
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// bound service account tokens are rotated by the kubelet
+	tokenReloadInterval = 1 * time.Minute
+
+	requestTimeout  = 30 * time.Second
+	validateTimeout = 10 * time.Second
+)
+
+// ErrNotConfigured is returned when neither an API server is configured nor
+// the process runs in a cluster.
+var ErrNotConfigured = errors.New("kubernetes API server is not configured")
+
+// Options configures the Kubernetes client.
+type Options struct {
+	// APIServer is the API server URL, empty for in-cluster discovery.
+	APIServer string
+	// CAFile verifies the API server, the system roots are used when empty.
+	CAFile string
+	// TokenFile holds the service account token, it is optional.
+	TokenFile string
+	// AuthMode is one of the AuthMode constants, impersonate when empty.
+	AuthMode string
+	// UserPrefix and GroupPrefix are prepended to impersonated identities.
+	UserPrefix  string
+	GroupPrefix string
+}
+
+// Client is a minimal Kubernetes API client for JSON REST calls.
+type Client struct {
+	server      *url.URL
+	httpClient  *http.Client
+	tokenFile   string
+	authMode    string
+	userPrefix  string
+	groupPrefix string
+
+	m           sync.Mutex
+	token       string
+	tokenLoaded time.Time
+}
+
+var (
+	_client *Client = nil
+)
+
+// GetClient returns the client built by Setup, nil when Kubernetes is not
+// configured.
+func GetClient() *Client {
+	return _client
+}
+
+// Setup builds the client from the options. Without an API server the
+// in-cluster service account is used. The service account token
+// authenticates the client when it exists.
+func Setup(opts Options) error {
+	if opts.TokenFile == "" {
+		opts.TokenFile = serviceAccountDir + "/token"
+	}
+
+	if opts.APIServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+
+		if host == "" || port == "" {
+			return ErrNotConfigured
+		}
+
+		opts.APIServer = "https://" + net.JoinHostPort(host, port)
+
+		if opts.CAFile == "" {
+			opts.CAFile = serviceAccountDir + "/ca.crt"
+		}
+
+		slog.Info("Using in-cluster Kubernetes configuration", "server", opts.APIServer)
+	}
+
+	c, err := NewClient(opts)
+
+	if err != nil {
+		return err
+	}
+
+	_client = c
+
+	return nil
+}
+
+// NewClient creates a client of the API server. The service account token
+// is read from the token file when the file exists.
+func NewClient(opts Options) (*Client, error) {
+	server, err := url.Parse(strings.TrimSuffix(opts.APIServer, "/"))
+
+	if err != nil || (server.Scheme != "https" && server.Scheme != "http") || server.Host == "" {
+		return nil, fmt.Errorf("invalid kubernetes API server: %s", opts.APIServer)
+	}
+
+	// tokens and impersonation headers must not cross the network in clear
+	if server.Scheme == "http" && !isLoopback(server.Hostname()) {
+		return nil, fmt.Errorf("kubernetes API server must use https unless it is on the loopback interface: %s", opts.APIServer)
+	}
+
+	authMode, err := parseAuthMode(opts.AuthMode)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.CAFile != "" {
+		caPEM, err := os.ReadFile(opts.CAFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no certificates found in kubernetes CA file")
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	tokenFile := opts.TokenFile
+
+	if _, err := os.Stat(tokenFile); err != nil {
+		// unauthenticated, e.g. a local proxy started with kubectl proxy
+		tokenFile = ""
+	}
+
+	return &Client{
+		server:      server,
+		httpClient:  &http.Client{Transport: transport},
+		tokenFile:   tokenFile,
+		authMode:    authMode,
+		userPrefix:  opts.UserPrefix,
+		groupPrefix: opts.GroupPrefix,
+	}, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// Server returns the API server URL.
+func (c *Client) Server() string {
+	return c.server.String()
+}
+
+// AuthMode returns whose identity requests are made with.
+func (c *Client) AuthMode() string {
+	return c.authMode
+}
+
+func (c *Client) bearerToken() (string, error) {
+	if c.tokenFile == "" {
+		return "", nil
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.token != "" && time.Since(c.tokenLoaded) < tokenReloadInterval {
+		return c.token, nil
+	}
+
+	buf, err := os.ReadFile(c.tokenFile)
+
+	if err != nil {
+		if c.token != "" {
+			slog.Warn("Failed to reload service account token, using previous one", "error", err)
+			return c.token, nil
+		}
+
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	c.token = strings.TrimSpace(string(buf))
+	c.tokenLoaded = time.Now()
+
+	return c.token, nil
+}
+
+// Do sends a request to the API server path as the user of ctx, see WithUser.
+// Requests without a deadline get a default timeout unless stream is set, the
+// caller closes the body.
+func (c *Client) Do(ctx context.Context, method string, path string, query url.Values, stream bool) (*http.Response, error) {
+	u := *c.server
+	u.Path = c.server.Path + path
+	u.RawQuery = query.Encode()
+
+	ctx, span := tracing.Start(ctx, "kubernetes "+method, trace.SpanKindClient,
+		attribute.String("http.request.method", method),
+		attribute.String("url.path", u.Path),
+		attribute.String("server.address", u.Hostname()),
+	)
+	defer span.End()
+
+	cancel := context.CancelFunc(func() {})
+
+	if _, ok := ctx.Deadline(); !ok && !stream {
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	if err := c.authenticate(ctx, req); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+
+	if err != nil {
+		cancel()
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("kubernetes request failed: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer cancel()
+		defer resp.Body.Close()
+
+		err := statusError(resp)
+		tracing.RecordError(span, err)
+
+		return nil, err
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+// Get decodes the JSON object at the API server path into out.
+func (c *Client) Get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	resp, err := c.Do(ctx, http.MethodGet, path, query, false)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode kubernetes response: %w", err)
+	}
+
+	return nil
+}
+
+// Validate checks the API server is reachable and accepts the service
+// account.
+func (c *Client) Validate(ctx context.Context) (*VersionInfo, error) {
+	ctx, cancel := context.WithTimeout(AsServiceAccount(ctx), validateTimeout)
+	defer cancel()
+
+	var v VersionInfo
+
+	if err := c.Get(ctx, "/version", nil, &v); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// Ping checks the API server answers. Errors of the API, such as missing
+// permissions, count as reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	resp, err := c.Do(AsServiceAccount(ctx), http.MethodGet, "/readyz", nil, false)
+
+	if err != nil {
+		var statusErr *StatusError
+
+		if errors.As(err, &statusErr) && statusErr.Code < http.StatusInternalServerError {
+			return nil
+		}
+
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
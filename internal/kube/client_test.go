@@ -0,0 +1,42 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package kube
+
+import (
+	"testing"
+)
+
+func TestNewClientServer(t *testing.T) {
+	tests := []struct {
+		server  string
+		wantErr bool
+	}{
+		{"https://kubernetes.example.com:6443", false},
+		{"https://10.0.0.1/", false},
+		{"http://localhost:8001", false},
+		{"http://127.0.0.1:8001", false},
+		{"http://[::1]:8001", false},
+		{"http://kubernetes.example.com:8080", true},
+		{"http://10.0.0.1:8080", true},
+		{"http://localhost.example.com:8001", true},
+		{"ftp://localhost", true},
+		{"https://", true},
+		{"localhost:8001", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			c, err := NewClient(Options{APIServer: tt.server, TokenFile: "/nonexistent"})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClient(%q) error = %v, wantErr %v", tt.server, err, tt.wantErr)
+			}
+
+			if err == nil && c.Server() == "" {
+				t.Errorf("Server() is empty")
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Auth modes select whose identity Kubernetes calls are made with.
+const (
+	// AuthModeImpersonate sends the caller as Impersonate-User and
+	// Impersonate-Group, the service account needs the impersonate verb.
+	AuthModeImpersonate = "impersonate"
+	// AuthModeToken passes the caller's bearer token, the API server must
+	// trust the same OIDC issuer and audience.
+	AuthModeToken = "token"
+	// AuthModeServiceAccount makes every call as the service account.
+	AuthModeServiceAccount = "serviceaccount"
+)
+
+// reserved for identities of cluster components, never impersonated
+const systemPrefix = "system:"
+
+var (
+	// ErrNoUser is returned when a request needs the caller's identity but
+	// the context carries none.
+	ErrNoUser = errors.New("kubernetes request has no user")
+	// ErrReservedUser is returned for users which must not be impersonated.
+	ErrReservedUser = errors.New("user name is reserved by kubernetes")
+)
+
+// User is the caller Kubernetes requests are made for.
+type User struct {
+	Name   string
+	Groups []string
+	// Token is the caller's bearer token, used by AuthModeToken.
+	Token string
+}
+
+type userContextKey struct{}
+
+type serviceAccountContextKey struct{}
+
+// WithUser returns a copy of ctx whose Kubernetes requests are made as the
+// user.
+func WithUser(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, u)
+}
+
+// AsServiceAccount returns a copy of ctx whose Kubernetes requests are made
+// as the service account regardless of the auth mode. It is meant for the
+// server's own calls such as health checks.
+func AsServiceAccount(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serviceAccountContextKey{}, true)
+}
+
+func userFromContext(ctx context.Context) (*User, bool) {
+	u, ok := ctx.Value(userContextKey{}).(*User)
+	return u, ok && u != nil
+}
+
+func parseAuthMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return AuthModeImpersonate, nil
+	case AuthModeImpersonate, AuthModeToken, AuthModeServiceAccount:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unknown kubernetes auth mode: %s", mode)
+}
+
+// authenticate sets the credentials of the request. Requests of a user fail
+// closed: without a user only explicit service account calls are sent, so a
+// forgotten WithUser never runs with the service account's permissions.
+func (c *Client) authenticate(ctx context.Context, req *http.Request) error {
+	asServiceAccount, _ := ctx.Value(serviceAccountContextKey{}).(bool)
+
+	if asServiceAccount || c.authMode == AuthModeServiceAccount {
+		return c.setServiceAccountToken(req)
+	}
+
+	user, ok := userFromContext(ctx)
+
+	if !ok {
+		return ErrNoUser
+	}
+
+	if c.authMode == AuthModeToken {
+		if user.Token == "" {
+			return ErrNoUser
+		}
+
+		req.Header.Set("Authorization", "Bearer "+user.Token)
+
+		return nil
+	}
+
+	// prefixes keep OIDC identities apart from cluster ones, like the
+	// --oidc-username-prefix and --oidc-groups-prefix of the API server
+	name := c.userPrefix + user.Name
+
+	if user.Name == "" || strings.HasPrefix(name, systemPrefix) {
+		return fmt.Errorf("%w: %s", ErrReservedUser, name)
+	}
+
+	if err := c.setServiceAccountToken(req); err != nil {
+		return err
+	}
+
+	req.Header.Set("Impersonate-User", name)
+
+	for _, g := range user.Groups {
+		group := c.groupPrefix + g
+
+		// e.g. system:masters from a misconfigured identity provider
+		if strings.HasPrefix(group, systemPrefix) {
+			continue
+		}
+
+		req.Header.Add("Impersonate-Group", group)
+	}
+
+	return nil
+}
+
+func (c *Client) setServiceAccountToken(req *http.Request) error {
+	token, err := c.bearerToken()
+
+	if err != nil {
+		return err
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package kube
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+
+	if err := os.WriteFile(tokenFile, []byte("sa-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{Name: "alice", Groups: []string{"dev", "system:masters", "ops"}, Token: "user-token"}
+
+	tests := []struct {
+		name        string
+		authMode    string
+		userPrefix  string
+		groupPrefix string
+		ctx         context.Context
+		wantErr     error
+		wantAuth    string
+		wantUser    string
+		wantGroups  []string
+	}{
+		{
+			name:       "impersonate",
+			authMode:   AuthModeImpersonate,
+			ctx:        WithUser(context.Background(), user),
+			wantAuth:   "Bearer sa-token",
+			wantUser:   "alice",
+			wantGroups: []string{"dev", "ops"},
+		},
+		{
+			name:        "impersonate with prefixes",
+			authMode:    AuthModeImpersonate,
+			userPrefix:  "oidc:",
+			groupPrefix: "oidc:",
+			ctx:         WithUser(context.Background(), user),
+			wantAuth:    "Bearer sa-token",
+			wantUser:    "oidc:alice",
+			wantGroups:  []string{"oidc:dev", "oidc:system:masters", "oidc:ops"},
+		},
+		{
+			name:        "group prefix making a system group",
+			authMode:    AuthModeImpersonate,
+			groupPrefix: "system:",
+			ctx:         WithUser(context.Background(), user),
+			wantAuth:    "Bearer sa-token",
+			wantUser:    "alice",
+			wantGroups:  nil,
+		},
+		{
+			name:     "system user",
+			authMode: AuthModeImpersonate,
+			ctx:      WithUser(context.Background(), &User{Name: "system:admin"}),
+			wantErr:  ErrReservedUser,
+		},
+		{
+			name:       "user prefix making a system user",
+			authMode:   AuthModeImpersonate,
+			userPrefix: "system:",
+			ctx:        WithUser(context.Background(), user),
+			wantErr:    ErrReservedUser,
+		},
+		{
+			name:     "empty user name",
+			authMode: AuthModeImpersonate,
+			ctx:      WithUser(context.Background(), &User{Groups: []string{"dev"}}),
+			wantErr:  ErrReservedUser,
+		},
+		{
+			name:     "no user",
+			authMode: AuthModeImpersonate,
+			ctx:      context.Background(),
+			wantErr:  ErrNoUser,
+		},
+		{
+			name:     "token",
+			authMode: AuthModeToken,
+			ctx:      WithUser(context.Background(), user),
+			wantAuth: "Bearer user-token",
+		},
+		{
+			name:     "token without user token",
+			authMode: AuthModeToken,
+			ctx:      WithUser(context.Background(), &User{Name: "alice"}),
+			wantErr:  ErrNoUser,
+		},
+		{
+			name:     "service account mode",
+			authMode: AuthModeServiceAccount,
+			ctx:      context.Background(),
+			wantAuth: "Bearer sa-token",
+		},
+		{
+			name:     "explicit service account",
+			authMode: AuthModeImpersonate,
+			ctx:      AsServiceAccount(WithUser(context.Background(), user)),
+			wantAuth: "Bearer sa-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{
+				tokenFile:   tokenFile,
+				authMode:    tt.authMode,
+				userPrefix:  tt.userPrefix,
+				groupPrefix: tt.groupPrefix,
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, "https://kubernetes/version", nil)
+
+			err := c.authenticate(tt.ctx, req)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("authenticate() error = %v, want %v", err, tt.wantErr)
+				}
+
+				if len(req.Header) != 0 {
+					t.Errorf("headers set on a rejected request: %v", req.Header)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("authenticate() error = %v", err)
+			}
+
+			if got := req.Header.Get("Authorization"); got != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", got, tt.wantAuth)
+			}
+
+			if got := req.Header.Get("Impersonate-User"); got != tt.wantUser {
+				t.Errorf("Impersonate-User = %q, want %q", got, tt.wantUser)
+			}
+
+			if got := req.Header.Values("Impersonate-Group"); !reflect.DeepEqual(got, tt.wantGroups) {
+				t.Errorf("Impersonate-Group = %q, want %q", got, tt.wantGroups)
+			}
+		})
+	}
+}
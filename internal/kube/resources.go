@@ -0,0 +1,56 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrInvalidNamespace is returned for namespaces which are not DNS-1123
+// labels, such names can not exist and must not reach a request path.
+var ErrInvalidNamespace = errors.New("invalid namespace")
+
+var dns1123Label = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// ValidateNamespace checks that the namespace is a DNS-1123 label.
+func ValidateNamespace(namespace string) error {
+	if len(namespace) > 63 || !dns1123Label.MatchString(namespace) {
+		return fmt.Errorf("%w: %q", ErrInvalidNamespace, namespace)
+	}
+
+	return nil
+}
+
+// ListNamespaces lists the namespaces of the cluster.
+func (c *Client) ListNamespaces(ctx context.Context, opts ListOptions) (*NamespaceList, error) {
+	var list NamespaceList
+
+	if err := c.Get(ctx, "/api/v1/namespaces", opts.query(), &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// ListPods lists the pods of the namespace, or of all namespaces when it is
+// empty.
+func (c *Client) ListPods(ctx context.Context, namespace string, opts ListOptions) (*PodList, error) {
+	path, err := watchableResources["pods"].path(namespace)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var list PodList
+
+	if err := c.Get(ctx, path, opts.query(), &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
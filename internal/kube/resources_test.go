@@ -0,0 +1,55 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package kube
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResourcePath(t *testing.T) {
+	pods, _ := LookupResource("pods")
+	namespaces, _ := LookupResource("namespaces")
+
+	tests := []struct {
+		name      string
+		resource  Resource
+		namespace string
+		want      string
+		wantErr   bool
+	}{
+		{"all namespaces", pods, "", "/api/v1/pods", false},
+		{"namespace", pods, "kube-system", "/api/v1/namespaces/kube-system/pods", false},
+		{"longest namespace", pods, strings.Repeat("a", 63), "/api/v1/namespaces/" + strings.Repeat("a", 63) + "/pods", false},
+		{"cluster scoped ignores namespace", namespaces, "../x", "/api/v1/namespaces", false},
+		{"dot dot", pods, "..", "", true},
+		{"slash", pods, "a/b", "", true},
+		{"escaped slash", pods, "a%2Fb", "", true},
+		{"upper case", pods, "Default", "", true},
+		{"leading dash", pods, "-a", "", true},
+		{"trailing dash", pods, "a-", "", true},
+		{"dot", pods, "a.b", "", true},
+		{"too long", pods, strings.Repeat("a", 64), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.resource.path(tt.namespace)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidNamespace) {
+					t.Errorf("path() = %q, %v, want %v", got, err, ErrInvalidNamespace)
+				}
+
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Errorf("path() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
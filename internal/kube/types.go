@@ -0,0 +1,131 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// The types below hold the fields of the API objects this application
+// reads, other fields are dropped while decoding.
+
+type VersionInfo struct {
+	Major      string `json:"major"`
+	Minor      string `json:"minor"`
+	GitVersion string `json:"gitVersion"`
+	Platform   string `json:"platform"`
+}
+
+type ObjectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace,omitempty"`
+	UID               string            `json:"uid"`
+	ResourceVersion   string            `json:"resourceVersion"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+	Labels            map[string]string `json:"labels,omitempty"`
+}
+
+type ListMeta struct {
+	ResourceVersion string `json:"resourceVersion"`
+	Continue        string `json:"continue,omitempty"`
+}
+
+type Namespace struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+}
+
+type NamespaceList struct {
+	Metadata ListMeta    `json:"metadata"`
+	Items    []Namespace `json:"items"`
+}
+
+type ContainerStatus struct {
+	Name         string `json:"name"`
+	Ready        bool   `json:"ready"`
+	RestartCount int    `json:"restartCount"`
+}
+
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     struct {
+		NodeName   string `json:"nodeName"`
+		Containers []struct {
+			Name string `json:"name"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase             string            `json:"phase"`
+		ContainerStatuses []ContainerStatus `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+type PodList struct {
+	Metadata ListMeta `json:"metadata"`
+	Items    []Pod    `json:"items"`
+}
+
+// ListOptions are the common query parameters of list calls.
+type ListOptions struct {
+	LabelSelector string
+	FieldSelector string
+	Limit         int
+	Continue      string
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+
+	if o.LabelSelector != "" {
+		q.Set("labelSelector", o.LabelSelector)
+	}
+
+	if o.FieldSelector != "" {
+		q.Set("fieldSelector", o.FieldSelector)
+	}
+
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+
+	if o.Continue != "" {
+		q.Set("continue", o.Continue)
+	}
+
+	return q
+}
+
+// StatusError is a failure status returned by the API server.
+type StatusError struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kubernetes API error %d %s: %s", e.Code, e.Reason, e.Message)
+}
+
+func statusError(resp *http.Response) error {
+	e := &StatusError{}
+
+	buf, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	if err := json.Unmarshal(buf, e); err != nil || e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+
+	e.Code = resp.StatusCode
+
+	return e
+}
@@ -0,0 +1,196 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Watch event types of the API server.
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+	EventBookmark = "BOOKMARK"
+	EventError    = "ERROR"
+)
+
+// Resource is a kind of API object which can be listed and watched.
+type Resource struct {
+	// Name is the plural resource name, e.g. pods.
+	Name string
+	// GroupVersion is the API path prefix, /api/v1 for the core group.
+	GroupVersion string
+	Namespaced   bool
+}
+
+// watchableResources are the kinds offered to the frontend. Secrets are left
+// out on purpose, they must not reach a browser even when RBAC allows it.
+var watchableResources = map[string]Resource{
+	"pods":         {Name: "pods", GroupVersion: "/api/v1", Namespaced: true},
+	"events":       {Name: "events", GroupVersion: "/api/v1", Namespaced: true},
+	"services":     {Name: "services", GroupVersion: "/api/v1", Namespaced: true},
+	"namespaces":   {Name: "namespaces", GroupVersion: "/api/v1"},
+	"nodes":        {Name: "nodes", GroupVersion: "/api/v1"},
+	"deployments":  {Name: "deployments", GroupVersion: "/apis/apps/v1", Namespaced: true},
+	"replicasets":  {Name: "replicasets", GroupVersion: "/apis/apps/v1", Namespaced: true},
+	"statefulsets": {Name: "statefulsets", GroupVersion: "/apis/apps/v1", Namespaced: true},
+	"daemonsets":   {Name: "daemonsets", GroupVersion: "/apis/apps/v1", Namespaced: true},
+	"jobs":         {Name: "jobs", GroupVersion: "/apis/batch/v1", Namespaced: true},
+	"cronjobs":     {Name: "cronjobs", GroupVersion: "/apis/batch/v1", Namespaced: true},
+}
+
+// LookupResource returns the watchable resource with the plural name.
+func LookupResource(name string) (Resource, bool) {
+	r, ok := watchableResources[name]
+	return r, ok
+}
+
+// path returns the collection path of the resource, namespace is ignored for
+// cluster scoped resources and means all namespaces when empty.
+func (r Resource) path(namespace string) (string, error) {
+	if !r.Namespaced || namespace == "" {
+		return r.GroupVersion + "/" + r.Name, nil
+	}
+
+	if err := ValidateNamespace(namespace); err != nil {
+		return "", err
+	}
+
+	return r.GroupVersion + "/namespaces/" + namespace + "/" + r.Name, nil
+}
+
+// RawList is a page of objects of any resource.
+type RawList struct {
+	Metadata ListMeta          `json:"metadata"`
+	Items    []json.RawMessage `json:"items"`
+}
+
+// List lists a page of the resource, a following watch resumes from the
+// resource version of the list.
+func (c *Client) List(ctx context.Context, r Resource, namespace string, opts ListOptions) (*RawList, error) {
+	path, err := r.path(namespace)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var list RawList
+
+	if err := c.Get(ctx, path, opts.query(), &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// WatchEvent is a change notification of a watch.
+type WatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// ResourceVersion returns the resource version of the event's object.
+func (e WatchEvent) ResourceVersion() string {
+	var obj struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(e.Object, &obj); err != nil {
+		return ""
+	}
+
+	return obj.Metadata.ResourceVersion
+}
+
+// WatchOptions are the query parameters of a watch.
+type WatchOptions struct {
+	// ResourceVersion resumes the watch after this version. When empty the
+	// watch starts with ADDED events of all existing objects.
+	ResourceVersion string
+	// TimeoutSeconds lets the API server end the watch, callers restart it
+	// from the last seen version.
+	TimeoutSeconds int
+	LabelSelector  string
+	FieldSelector  string
+}
+
+// Watcher reads the events of a watch.
+type Watcher struct {
+	body    io.ReadCloser
+	decoder *json.Decoder
+}
+
+// Watch starts a watch of the resource with bookmarks enabled. The watch runs
+// until ctx is done, the API server ends it or Close is called.
+func (c *Client) Watch(ctx context.Context, r Resource, namespace string, opts WatchOptions) (*Watcher, error) {
+	path, err := r.path(namespace)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{}
+	q.Set("watch", "1")
+	q.Set("allowWatchBookmarks", "true")
+
+	if opts.ResourceVersion != "" {
+		q.Set("resourceVersion", opts.ResourceVersion)
+	}
+
+	if opts.TimeoutSeconds > 0 {
+		q.Set("timeoutSeconds", strconv.Itoa(opts.TimeoutSeconds))
+	}
+
+	if opts.LabelSelector != "" {
+		q.Set("labelSelector", opts.LabelSelector)
+	}
+
+	if opts.FieldSelector != "" {
+		q.Set("fieldSelector", opts.FieldSelector)
+	}
+
+	resp, err := c.Do(ctx, http.MethodGet, path, q, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{body: resp.Body, decoder: json.NewDecoder(resp.Body)}, nil
+}
+
+// Next blocks until the next event. An ERROR event is returned as a
+// *StatusError, io.EOF means the API server ended the watch.
+func (w *Watcher) Next() (WatchEvent, error) {
+	var e WatchEvent
+
+	if err := w.decoder.Decode(&e); err != nil {
+		return WatchEvent{}, err
+	}
+
+	if e.Type == EventError {
+		statusErr := &StatusError{}
+
+		if err := json.Unmarshal(e.Object, statusErr); err != nil {
+			return WatchEvent{}, fmt.Errorf("failed to decode watch error: %w", err)
+		}
+
+		return WatchEvent{}, statusErr
+	}
+
+	return e, nil
+}
+
+func (w *Watcher) Close() error {
+	return w.body.Close()
+}
@@ -5,50 +5,494 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
 
-	"github.com/gorilla/handlers"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func HttpLogFormater(writer io.Writer, params handlers.LogFormatterParams) {
-	req := params.Request
+const (
+	AccessLogCommon   = "common"
+	AccessLogCombined = "combined"
+	AccessLogJSON     = "json"
+	AccessLogCustom   = "custom"
 
-	username := "-"
-	if params.URL.User != nil {
-		if name := params.URL.User.Username(); name != "" {
-			username = name
+	// AccessLogOutputApp writes access records through the application logger.
+	AccessLogOutputApp = "app"
+	AccessLogOutputOff = "off"
+
+	clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+// AccessLogOptions configures the HTTP access log.
+type AccessLogOptions struct {
+	// Format is common, combined, json or custom. It is not used when the
+	// output is the application logger.
+	Format string
+	// Template is the text/template of the custom format, executed with an
+	// AccessLogEntry.
+	Template string
+	// Output is app, off, stderr, stdout or file.
+	Output string
+	File   string
+	Rotate RotateOptions
+	// ExcludePaths are path.Match patterns of request paths not logged,
+	// such as health probes.
+	ExcludePaths []string
+	// SampleRate is the share of requests logged, between 0 and 1. Server
+	// errors are always logged.
+	SampleRate float64
+}
+
+// AccessLogEntry is a record of the access log.
+type AccessLogEntry struct {
+	Time       time.Time
+	RemoteHost string
+	User       string
+	Method     string
+	URI        string
+	Proto      string
+	Host       string
+	Status     int
+	BytesIn    int64
+	BytesOut   int64
+	Duration   time.Duration
+	Referer    string
+	UserAgent  string
+	RequestID  string
+	TraceID    string
+	TLSVersion string
+	TLSCipher  string
+}
+
+type accessLogger struct {
+	opts     AccessLogOptions
+	format   func(e *AccessLogEntry) ([]byte, error)
+	m        sync.Mutex
+	writer   io.Writer
+	file     *RotatingFile
+	disabled bool
+}
+
+var accessLog atomic.Pointer[accessLogger]
+
+func init() {
+	accessLog.Store(&accessLogger{opts: AccessLogOptions{Output: AccessLogOutputApp, SampleRate: 1}})
+}
+
+// SetupAccessLog configures the access log, by default it goes through the
+// application logger.
+func SetupAccessLog(opts AccessLogOptions) error {
+	if opts.SampleRate < 0 || opts.SampleRate > 1 {
+		return fmt.Errorf("access log sample rate must be between 0 and 1: %v", opts.SampleRate)
+	}
+
+	for _, pattern := range opts.ExcludePaths {
+		if _, err := path.Match(pattern, "/"); err != nil {
+			return fmt.Errorf("invalid access log exclude pattern %q: %w", pattern, err)
+		}
+	}
+
+	l := &accessLogger{opts: opts}
+
+	switch opts.Output {
+	case AccessLogOutputApp, "":
+		l.opts.Output = AccessLogOutputApp
+	case AccessLogOutputOff:
+		l.disabled = true
+	default:
+		format, err := accessLogFormatter(opts.Format, opts.Template)
+
+		if err != nil {
+			return err
+		}
+
+		w, file, err := openOutput(Options{Output: opts.Output, File: opts.File, Rotate: opts.Rotate})
+
+		if err != nil {
+			return fmt.Errorf("access log: %w", err)
+		}
+
+		l.format = format
+		l.writer = w
+		l.file = file
+	}
+
+	if old := accessLog.Swap(l); old != nil && old.file != nil {
+		old.file.Close()
+	}
+
+	return nil
+}
+
+func accessLogFormatter(format, tpl string) (func(e *AccessLogEntry) ([]byte, error), error) {
+	switch format {
+	case AccessLogCombined, "":
+		return formatCombined, nil
+	case AccessLogCommon:
+		return formatCommon, nil
+	case AccessLogJSON:
+		return formatJSON, nil
+	case AccessLogCustom:
+		if tpl == "" {
+			return nil, fmt.Errorf("access log template is required for %s format", AccessLogCustom)
+		}
+
+		t, err := template.New("accesslog").Parse(tpl)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid access log template: %w", err)
+		}
+
+		return func(e *AccessLogEntry) ([]byte, error) {
+			var b bytes.Buffer
+
+			if err := t.Execute(&b, e); err != nil {
+				return nil, err
+			}
+
+			if b.Len() == 0 || b.Bytes()[b.Len()-1] != '\n' {
+				b.WriteByte('\n')
+			}
+
+			return b.Bytes(), nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported access log format: %s", format)
+}
+
+// AccessLogHandler logs the requests served by next. It must run inside the
+// request id, tracing and request user middlewares to report them.
+func AccessLogHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := accessLog.Load()
+
+		if l.disabled || l.excluded(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+
+		// handlers may rewrite the url, keep what the client sent
+		method := r.Method
+		uri := requestURI(r)
+
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+
+		aw := &accessLogWriter{ResponseWriter: w}
+
+		next.ServeHTTP(aw, r)
+
+		status := aw.statusCode()
+
+		if status < http.StatusInternalServerError && !l.sampled() {
+			return
+		}
+
+		e := &AccessLogEntry{
+			Time:       start,
+			RemoteHost: remoteHost(r),
+			User:       requestUser(r),
+			Method:     method,
+			URI:        uri,
+			Proto:      r.Proto,
+			Host:       r.Host,
+			Status:     status,
+			BytesIn:    body.n,
+			BytesOut:   aw.size,
+			Duration:   time.Since(start),
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+			RequestID:  RequestID(r.Context()),
+		}
+
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			e.TraceID = sc.TraceID().String()
 		}
+
+		if r.TLS != nil {
+			e.TLSVersion = tls.VersionName(r.TLS.Version)
+			e.TLSCipher = tls.CipherSuiteName(r.TLS.CipherSuite)
+		}
+
+		l.log(r.Context(), e)
+	})
+}
+
+func (l *accessLogger) excluded(p string) bool {
+	for _, pattern := range l.opts.ExcludePaths {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *accessLogger) sampled() bool {
+	return l.opts.SampleRate >= 1 || rand.Float64() < l.opts.SampleRate
+}
+
+func (l *accessLogger) log(ctx context.Context, e *AccessLogEntry) {
+	if l.opts.Output == AccessLogOutputApp {
+		attrs := []slog.Attr{
+			slog.String("host", e.RemoteHost),
+			slog.String("username", e.User),
+			slog.String("timestamp", e.Time.UTC().Format(clfTimeFormat)),
+			slog.String("method", e.Method),
+			slog.String("uri", e.URI),
+			slog.String("proto", e.Proto),
+			slog.Int("status", e.Status),
+			slog.Int64("size", e.BytesOut),
+			slog.Int64("bytes_in", e.BytesIn),
+			slog.Float64("duration_ms", float64(e.Duration.Microseconds())/1000),
+			slog.String("referer", e.Referer),
+			slog.String("user_agent", e.UserAgent),
+		}
+
+		if e.TLSVersion != "" {
+			attrs = append(attrs, slog.String("tls_version", e.TLSVersion), slog.String("tls_cipher", e.TLSCipher))
+		}
+
+		// request and trace ids are added by ContextHandler
+		slog.LogAttrs(ctx, slog.LevelInfo, "http request", attrs...)
+
+		return
+	}
+
+	line, err := l.format(e)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Error formatting access log record", "error", err)
+		return
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if _, err := l.writer.Write(line); err != nil {
+		slog.ErrorContext(ctx, "Error writing access log", "error", err)
 	}
+}
+
+func formatCommon(e *AccessLogEntry) ([]byte, error) {
+	var b bytes.Buffer
+
+	writeCommon(&b, e)
+	b.WriteByte('\n')
+
+	return b.Bytes(), nil
+}
+
+func formatCombined(e *AccessLogEntry) ([]byte, error) {
+	var b bytes.Buffer
+
+	writeCommon(&b, e)
+	b.WriteString(` "`)
+	b.WriteString(clfField(e.Referer))
+	b.WriteString(`" "`)
+	b.WriteString(clfField(e.UserAgent))
+	b.WriteString("\"\n")
+
+	return b.Bytes(), nil
+}
+
+// writeCommon writes host ident authuser [date] "request" status bytes.
+func writeCommon(b *bytes.Buffer, e *AccessLogEntry) {
+	b.WriteString(clfField(e.RemoteHost))
+	b.WriteString(" - ")
+	b.WriteString(clfField(e.User))
+	b.WriteString(" [")
+	b.WriteString(e.Time.Format(clfTimeFormat))
+	b.WriteString(`] "`)
+	b.WriteString(clfEscape(e.Method))
+	b.WriteByte(' ')
+	b.WriteString(clfEscape(e.URI))
+	b.WriteByte(' ')
+	b.WriteString(clfEscape(e.Proto))
+	b.WriteString(`" `)
+	b.WriteString(strconv.Itoa(e.Status))
+	b.WriteByte(' ')
+
+	if e.BytesOut == 0 {
+		b.WriteByte('-')
+	} else {
+		b.WriteString(strconv.FormatInt(e.BytesOut, 10))
+	}
+}
+
+func formatJSON(e *AccessLogEntry) ([]byte, error) {
+	buf, err := json.Marshal(map[string]interface{}{
+		"time":        e.Time.UTC().Format(time.RFC3339Nano),
+		"remote_host": e.RemoteHost,
+		"user":        e.User,
+		"method":      e.Method,
+		"uri":         e.URI,
+		"proto":       e.Proto,
+		"host":        e.Host,
+		"status":      e.Status,
+		"bytes_in":    e.BytesIn,
+		"bytes_out":   e.BytesOut,
+		"duration_ms": float64(e.Duration.Microseconds()) / 1000,
+		"referer":     e.Referer,
+		"user_agent":  e.UserAgent,
+		"request_id":  e.RequestID,
+		"trace_id":    e.TraceID,
+		"tls_version": e.TLSVersion,
+		"tls_cipher":  e.TLSCipher,
+	})
 
-	host, _, err := net.SplitHostPort(req.RemoteAddr)
 	if err != nil {
-		host = req.RemoteAddr
+		return nil, err
+	}
+
+	return append(buf, '\n'), nil
+}
+
+func clfField(v string) string {
+	if v == "" {
+		return "-"
+	}
+
+	return clfEscape(v)
+}
+
+// clfEscape escapes quotes and control characters so client supplied values
+// cannot break or forge lines.
+func clfEscape(v string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
 	}
 
-	uri := req.RequestURI
+	return b.String()
+}
 
+func requestURI(r *http.Request) string {
 	// Requests using the CONNECT method over HTTP/2.0 must use
 	// the authority field (aka r.Host) to identify the target.
 	// Refer: https://httpwg.github.io/specs/rfc7540.html#CONNECT
-	if req.ProtoMajor == 2 && req.Method == "CONNECT" {
-		uri = req.Host
-	}
-	if uri == "" {
-		uri = params.URL.RequestURI()
-	}
-
-	slog.Info("http request",
-		"host", host,
-		"username", username,
-		"timestamp", params.TimeStamp.UTC().Format("02/Jan/2006:15:04:05 -0700"),
-		"method", req.Method,
-		"uri", uri,
-		"proto", req.Proto,
-		"status", params.StatusCode,
-		"size", params.Size,
-		"referer", req.Referer(),
-		"user_agent", req.UserAgent(),
-	)
+	if r.ProtoMajor == 2 && r.Method == http.MethodConnect {
+		return r.Host
+	}
+
+	if r.RequestURI != "" {
+		return r.RequestURI
+	}
+
+	return r.URL.RequestURI()
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func requestUser(r *http.Request) string {
+	if name := RequestUser(r.Context()); name != "" {
+		return name
+	}
+
+	if r.URL.User != nil {
+		return r.URL.User.Username()
+	}
+
+	return ""
+}
+
+// countingReader counts the bytes of the request body read by handlers.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// accessLogWriter records the status and size of the response.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *accessLogWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+
+	return n, err
+}
+
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *accessLogWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
 }
@@ -5,17 +5,151 @@
 package logger
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"log/slog"
+	"os"
 )
 
+const (
+	FormatPretty = "pretty"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+
+	OutputStderr = "stderr"
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+)
+
+// Options selects the format and destination of the application log. The
+// HTTP access log is written through the same loggers.
+type Options struct {
+	Format string
+	Output string
+	// File is the path of the log file when Output is file.
+	File string
+	// Rotate controls rotation of the log file.
+	Rotate RotateOptions
+}
+
+// The default loggers are built by Setup at startup, before that slog uses
+// its standard logger.
 var (
-	LogLevel                    = new(slog.LevelVar)
-	DefaultHandler slog.Handler = NewHandler(&slog.HandlerOptions{
+	LogLevel                        = new(slog.LevelVar)
+	DefaultHandler     slog.Handler = nil
+	DefaultSLogger     *slog.Logger = nil
+	DefaultLogger      *log.Logger  = nil
+	DefaultErrorLogger *log.Logger  = nil
+
+	logFile *RotatingFile = nil
+)
+
+// Setup builds the default loggers from the options and makes them the slog
+// default.
+func Setup(opts Options) error {
+	w, file, err := openOutput(opts)
+
+	if err != nil {
+		return err
+	}
+
+	handlerOptions := &slog.HandlerOptions{
 		AddSource: true,
 		Level:     LogLevel,
-	})
-	DefaultSLogger     *slog.Logger = slog.New(DefaultHandler)
-	DefaultLogger      *log.Logger  = slog.NewLogLogger(DefaultHandler, slog.LevelInfo)
-	DefaultErrorLogger *log.Logger  = slog.NewLogLogger(DefaultHandler, slog.LevelError)
-)
+	}
+
+	var handler slog.Handler
+
+	switch opts.Format {
+	case FormatPretty, "":
+		options := []Option{WithDestinationWriter(w), WithOutputEmptyAttrs()}
+
+		if isTerminal(w) && os.Getenv("NO_COLOR") == "" {
+			options = append(options, WithColor())
+		}
+
+		handler = NewHandlerWithOptions(handlerOptions, options...)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, handlerOptions)
+	case FormatLogfmt:
+		handler = slog.NewTextHandler(w, handlerOptions)
+	default:
+		if file != nil {
+			file.Close()
+		}
+
+		return fmt.Errorf("unsupported log format: %s", opts.Format)
+	}
+
+	DefaultHandler = NewContextHandler(handler)
+	DefaultSLogger = slog.New(DefaultHandler)
+	DefaultLogger = slog.NewLogLogger(DefaultHandler, slog.LevelInfo)
+	DefaultErrorLogger = slog.NewLogLogger(DefaultHandler, slog.LevelError)
+
+	slog.SetDefault(DefaultSLogger)
+
+	if logFile != nil {
+		logFile.Close()
+	}
+
+	logFile = file
+
+	return nil
+}
+
+// Reopen reopens the application and access log files, external rotation
+// tools signal the process after moving them. Outputs other than files are
+// left alone.
+func Reopen() error {
+	var errs []error
+
+	if logFile != nil {
+		errs = append(errs, logFile.Reopen())
+	}
+
+	if l := accessLog.Load(); l.file != nil {
+		errs = append(errs, l.file.Reopen())
+	}
+
+	return errors.Join(errs...)
+}
+
+// openOutput returns the writer of the destination and the log file when the
+// destination is a file.
+func openOutput(opts Options) (io.Writer, *RotatingFile, error) {
+	switch opts.Output {
+	case OutputStderr, "":
+		return os.Stderr, nil, nil
+	case OutputStdout:
+		return os.Stdout, nil, nil
+	case OutputFile:
+		if opts.File == "" {
+			return nil, nil, fmt.Errorf("log file is required for %s output", OutputFile)
+		}
+
+		f, err := NewRotatingFile(opts.File, opts.Rotate)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return f, f, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported log output: %s", opts.Output)
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+
+	if !ok {
+		return false
+	}
+
+	fi, err := f.Stat()
+
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
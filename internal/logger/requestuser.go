@@ -0,0 +1,37 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package logger
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type requestUserKey struct{}
+
+// WithRequestUser returns a copy of ctx with an empty slot for the user name.
+// Handlers deeper in the chain fill it with SetRequestUser so the access log,
+// which only sees the outer request, can report the authenticated user.
+func WithRequestUser(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestUserKey{}, new(atomic.Value))
+}
+
+// SetRequestUser records the authenticated user name of the request.
+func SetRequestUser(ctx context.Context, username string) {
+	if slot, ok := ctx.Value(requestUserKey{}).(*atomic.Value); ok {
+		slot.Store(username)
+	}
+}
+
+// RequestUser returns the recorded user name of the request or empty string.
+func RequestUser(ctx context.Context) string {
+	if slot, ok := ctx.Value(requestUserKey{}).(*atomic.Value); ok {
+		if username, ok := slot.Load().(string); ok {
+			return username
+		}
+	}
+
+	return ""
+}
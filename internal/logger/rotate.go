@@ -0,0 +1,426 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateOptions controls rotation of a log file. Zero values disable the
+// related limit.
+type RotateOptions struct {
+	// MaxSize rotates the file before it grows beyond this many bytes.
+	MaxSize int64
+	// Interval rotates the file periodically.
+	Interval time.Duration
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups int
+	// MaxAge removes rotated files older than this.
+	MaxAge time.Duration
+	// Compress gzips rotated files.
+	Compress bool
+}
+
+// RotatingFile is a log file which rotates itself by size and interval.
+// Rotated files are named name-<timestamp>.ext next to the file. Reopen
+// supports external rotation tools which move the file away.
+type RotatingFile struct {
+	path   string
+	opts   RotateOptions
+	m      sync.Mutex
+	f      *os.File
+	size   int64
+	timer  *time.Timer
+	millCh chan struct{}
+	millWg sync.WaitGroup
+	closed bool
+}
+
+// NewRotatingFile opens the file for appending, creating it when missing.
+func NewRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path:   path,
+		opts:   opts,
+		millCh: make(chan struct{}, 1),
+	}
+
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+
+	rf.millWg.Add(1)
+	go rf.mill()
+
+	if opts.Interval > 0 {
+		rf.timer = time.AfterFunc(opts.Interval, rf.rotateOnInterval)
+	}
+
+	// apply retention to backups left by a previous run
+	rf.triggerMill()
+
+	return rf, nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.m.Lock()
+	defer rf.m.Unlock()
+
+	if rf.closed {
+		return 0, os.ErrClosed
+	}
+
+	if rf.opts.MaxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.opts.MaxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+
+	return n, err
+}
+
+// Rotate moves the current file to a backup and starts a new one.
+func (rf *RotatingFile) Rotate() error {
+	rf.m.Lock()
+	defer rf.m.Unlock()
+
+	if rf.closed {
+		return os.ErrClosed
+	}
+
+	return rf.rotate()
+}
+
+// Reopen closes and reopens the file at its path, for use after an external
+// tool such as logrotate moved it.
+func (rf *RotatingFile) Reopen() error {
+	rf.m.Lock()
+	defer rf.m.Unlock()
+
+	if rf.closed {
+		return os.ErrClosed
+	}
+
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+
+	return rf.open()
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.m.Lock()
+
+	if rf.closed {
+		rf.m.Unlock()
+		return nil
+	}
+
+	rf.closed = true
+
+	if rf.timer != nil {
+		rf.timer.Stop()
+	}
+
+	err := rf.f.Close()
+
+	close(rf.millCh)
+
+	rf.m.Unlock()
+
+	// let a running compression finish
+	rf.millWg.Wait()
+
+	return err
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	fi, err := f.Stat()
+
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	rf.f = f
+	rf.size = fi.Size()
+
+	return nil
+}
+
+// rotate does the rotation, the caller must hold rf.m.
+func (rf *RotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(rf.path, rf.nextBackupName(time.Now())); err != nil && !os.IsNotExist(err) {
+		// keep logging to the old file rather than losing records
+		if oerr := rf.open(); oerr != nil {
+			return oerr
+		}
+
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := rf.open(); err != nil {
+		return err
+	}
+
+	if rf.timer != nil {
+		rf.timer.Reset(rf.opts.Interval)
+	}
+
+	rf.triggerMill()
+
+	return nil
+}
+
+func (rf *RotatingFile) rotateOnInterval() {
+	rf.m.Lock()
+	defer rf.m.Unlock()
+
+	if rf.closed {
+		return
+	}
+
+	// an empty file is not worth a backup
+	if rf.size == 0 {
+		rf.timer.Reset(rf.opts.Interval)
+		return
+	}
+
+	if err := rf.rotate(); err != nil {
+		fmt.Fprintf(os.Stderr, "log file rotation failed: %v\n", err)
+		rf.timer.Reset(rf.opts.Interval)
+	}
+}
+
+// backupName returns the name of the backup rotated at t, a sequence number
+// above zero is put before the extension.
+func (rf *RotatingFile) backupName(t time.Time, seq int) string {
+	dir := filepath.Dir(rf.path)
+	base := filepath.Base(rf.path)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext) + "-" + t.Format(backupTimeFormat)
+
+	if seq > 0 {
+		name += "." + strconv.Itoa(seq)
+	}
+
+	return filepath.Join(dir, name+ext)
+}
+
+// nextBackupName returns the first backup name of t which is not taken,
+// rotations within the same millisecond must not overwrite a backup or its
+// compressed copy.
+func (rf *RotatingFile) nextBackupName(t time.Time) string {
+	for seq := 0; ; seq++ {
+		name := rf.backupName(t, seq)
+
+		if !fileExists(name) && !fileExists(name+".gz") {
+			return name
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return !os.IsNotExist(err)
+}
+
+func (rf *RotatingFile) triggerMill() {
+	select {
+	case rf.millCh <- struct{}{}:
+	default:
+	}
+}
+
+// mill compresses and removes backups in background so rotation never waits
+// on the disk.
+func (rf *RotatingFile) mill() {
+	defer rf.millWg.Done()
+
+	for range rf.millCh {
+		if err := rf.millOnce(); err != nil {
+			fmt.Fprintf(os.Stderr, "log file retention failed: %v\n", err)
+		}
+	}
+}
+
+type backupFile struct {
+	path       string
+	time       time.Time
+	seq        int
+	compressed bool
+}
+
+func (rf *RotatingFile) millOnce() error {
+	backups, err := rf.backups()
+
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+
+	now := time.Now()
+
+	for i, b := range backups {
+		expired := (rf.opts.MaxBackups > 0 && i >= rf.opts.MaxBackups) ||
+			(rf.opts.MaxAge > 0 && now.Sub(b.time) > rf.opts.MaxAge)
+
+		if expired {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err.Error())
+			}
+
+			continue
+		}
+
+		if rf.opts.Compress && !b.compressed {
+			if err := compressFile(b.path); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// backups lists the rotated files newest first.
+func (rf *RotatingFile) backups() ([]backupFile, error) {
+	dir := filepath.Dir(rf.path)
+	base := filepath.Base(rf.path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+
+		name := e.Name()
+		compressed := false
+
+		if n, ok := strings.CutSuffix(name, ".gz"); ok {
+			name = n
+			compressed = true
+		}
+
+		ts, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+
+		ts, ok = strings.CutSuffix(ts, ext)
+		if !ok {
+			continue
+		}
+
+		seq := 0
+
+		if i := len(backupTimeFormat); len(ts) > i && ts[i] == '.' {
+			n, err := strconv.Atoi(ts[i+1:])
+			if err != nil || n < 1 {
+				continue
+			}
+
+			ts, seq = ts[:i], n
+		}
+
+		t, err := time.ParseInLocation(backupTimeFormat, ts, time.Local)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, backupFile{path: filepath.Join(dir, e.Name()), time: t, seq: seq, compressed: compressed})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].time.Equal(backups[j].time) {
+			return backups[i].seq > backups[j].seq
+		}
+
+		return backups[i].time.After(backups[j].time)
+	})
+
+	return backups, nil
+}
+
+// compressFile gzips the file and removes it. The archive is written to a
+// temporary name first so a crash never leaves a truncated .gz behind.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+
+	_, err = io.Copy(gz, src)
+
+	if err == nil {
+		err = gz.Close()
+	}
+
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(path)
+}
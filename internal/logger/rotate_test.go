@@ -0,0 +1,215 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileRetention(t *testing.T) {
+	now := time.Now()
+
+	// backups by age in hours, the compressed ones are rotated earlier runs
+	ages := []int{1, 2, 48, 72}
+	compressed := map[int]bool{2: true}
+
+	tests := []struct {
+		name string
+		opts RotateOptions
+		want []string
+	}{
+		{"no limits", RotateOptions{}, []string{"1", "2.gz", "48", "72"}},
+		{"max backups", RotateOptions{MaxBackups: 2}, []string{"1", "2.gz"}},
+		{"max age", RotateOptions{MaxAge: 36 * time.Hour}, []string{"1", "2.gz"}},
+		{"max backups within max age", RotateOptions{MaxBackups: 1, MaxAge: 36 * time.Hour}, []string{"1"}},
+		{"compress", RotateOptions{Compress: true}, []string{"1.gz", "2.gz", "48.gz", "72.gz"}},
+		{"compress kept backups only", RotateOptions{MaxBackups: 3, Compress: true}, []string{"1.gz", "2.gz", "48.gz"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			rf := &RotatingFile{path: filepath.Join(dir, "app.log"), opts: tt.opts}
+
+			names := map[string]string{}
+
+			for _, age := range ages {
+				path := rf.backupName(now.Add(-time.Duration(age)*time.Hour), 0)
+				label := strconv.Itoa(age)
+
+				if compressed[age] {
+					path += ".gz"
+					label += ".gz"
+				}
+
+				writeTestFile(t, path)
+				names[filepath.Base(path)] = label
+				names[filepath.Base(path)+".gz"] = label + ".gz"
+			}
+
+			// files which are not backups of app.log are never touched
+			others := []string{"app.log", "other-2020-01-01T00-00-00.000.log", "app-invalid.log", "app-2020-01-01T00-00-00.000.txt"}
+
+			for _, name := range others {
+				writeTestFile(t, filepath.Join(dir, name))
+			}
+
+			if err := rf.millOnce(); err != nil {
+				t.Fatalf("millOnce: %v", err)
+			}
+
+			entries, err := os.ReadDir(dir)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got, rest []string
+
+			for _, e := range entries {
+				if label, ok := names[e.Name()]; ok {
+					got = append(got, label)
+				} else {
+					rest = append(rest, e.Name())
+				}
+			}
+
+			sort.Strings(got)
+			sort.Strings(others)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("backups = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(rest, others) {
+				t.Errorf("other files = %v, want %v", rest, others)
+			}
+		})
+	}
+}
+
+func TestRotatingFileRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	rf, err := NewRotatingFile(path, RotateOptions{MaxSize: 10})
+
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+
+	for _, line := range []string{"first\n", "second\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	if err := rf.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	backups, err := rf.backups()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backups) != 1 {
+		t.Fatalf("got %d backups, want 1", len(backups))
+	}
+
+	for file, want := range map[string]string{backups[0].path: "first\n", path: "second\n"} {
+		data, err := os.ReadFile(file)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), data, want)
+		}
+	}
+}
+
+func TestRotatingFileKeepsBackupsOfTheSameMillisecond(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	rf := &RotatingFile{path: path}
+
+	now := time.Now()
+
+	writeTestFile(t, rf.backupName(now, 0))
+	writeTestFile(t, rf.backupName(now, 1)+".gz")
+
+	if got, want := rf.nextBackupName(now), rf.backupName(now, 2); got != want {
+		t.Errorf("nextBackupName() = %s, want %s", filepath.Base(got), filepath.Base(want))
+	}
+
+	if err := os.Remove(rf.backupName(now, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(rf.backupName(now, 1) + ".gz"); err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := NewRotatingFile(path, RotateOptions{})
+
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+
+	// rotations in a row mostly share their millisecond
+	const rotations = 20
+
+	for i := 0; i < rotations; i++ {
+		if _, err := rf.Write([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+
+		if err := rf.Rotate(); err != nil {
+			t.Fatalf("rotate: %v", err)
+		}
+	}
+
+	if err := rf.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	backups, err := rf.backups()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backups) != rotations {
+		t.Fatalf("got %d backups, want %d", len(backups), rotations)
+	}
+
+	// newest first
+	for i, b := range backups {
+		data, err := os.ReadFile(b.path)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := strconv.Itoa(rotations - 1 - i); string(data) != want {
+			t.Errorf("backup %d %s = %q, want %q", i, filepath.Base(b.path), data, want)
+		}
+	}
+}
+
+func writeTestFile(t *testing.T, path string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte("log\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,160 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultSampler     = "parentbased_always_on"
+	DefaultServiceName = "go_react_mui"
+
+	instrumentationName = "github.com/kazimsarikaya/go_react_mui"
+)
+
+// Options configures trace export.
+type Options struct {
+	// Endpoint is the OTLP/HTTP collector URL such as http://localhost:4318,
+	// /v1/traces is appended when it has no path. Empty disables export.
+	Endpoint string
+	// Sampler is one of always_on, always_off, traceidratio and their
+	// parentbased_ variants.
+	Sampler        string
+	SamplerRatio   float64
+	ServiceName    string
+	ServiceVersion string
+}
+
+func init() {
+	// incoming trace context is propagated even when export is disabled
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+// Setup starts exporting spans with the options. The returned function
+// flushes queued spans and stops the export.
+func Setup(opts Options) (func(ctx context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint, err := tracesURL(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler, err := newSampler(opts.Sampler, opts.SamplerRatio)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ServiceName == "" {
+		opts.ServiceName = DefaultServiceName
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("service.name", opts.ServiceName)}
+
+	if opts.ServiceVersion != "" {
+		attrs = append(attrs, attribute.String("service.version", opts.ServiceVersion))
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewSchemaless(attrs...)),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("Trace export failed", "error", err)
+	}))
+
+	slog.Info("Tracing enabled", "endpoint", endpoint, "sampler", opts.Sampler, "service_name", opts.ServiceName)
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span or remote span context in ctx.
+func Start(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// RecordError marks the span failed when err is not nil.
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+func tracesURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid trace endpoint: %s", endpoint)
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+
+	return u.String(), nil
+}
+
+func newSampler(name string, ratio float64) (sdktrace.Sampler, error) {
+	if name == "" {
+		name = DefaultSampler
+	}
+
+	parentBased := false
+
+	if rest, ok := strings.CutPrefix(name, "parentbased_"); ok {
+		parentBased = true
+		name = rest
+	}
+
+	var root sdktrace.Sampler
+
+	switch name {
+	case "always_on":
+		root = sdktrace.AlwaysSample()
+	case "always_off":
+		root = sdktrace.NeverSample()
+	case "traceidratio":
+		if ratio < 0 || ratio > 1 || math.IsNaN(ratio) {
+			return nil, fmt.Errorf("trace sampler ratio must be between 0 and 1: %v", ratio)
+		}
+
+		root = sdktrace.TraceIDRatioBased(ratio)
+	default:
+		return nil, fmt.Errorf("unsupported trace sampler: %s", name)
+	}
+
+	if !parentBased {
+		return root, nil
+	}
+
+	return sdktrace.ParentBased(root), nil
+}
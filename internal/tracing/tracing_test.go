@@ -0,0 +1,170 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/tracing/tracingtest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracesURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		ok       bool
+	}{
+		{"http://localhost:4318", "http://localhost:4318/v1/traces", true},
+		{"http://localhost:4318/", "http://localhost:4318/v1/traces", true},
+		{"https://collector.example/otlp/v1/traces", "https://collector.example/otlp/v1/traces", true},
+		{"localhost:4318", "", false},
+		{"grpc://localhost:4317", "", false},
+		{"http://", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			got, err := tracesURL(tt.endpoint)
+
+			if (err == nil) != tt.ok || got != tt.want {
+				t.Errorf("tracesURL(%q) = %q, %v, want %q", tt.endpoint, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSamplerErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		ratio float64
+	}{
+		{"sometimes", 0},
+		{"parentbased_", 0},
+		{"traceidratio", -0.1},
+		{"parentbased_traceidratio", 1.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newSampler(tt.name, tt.ratio); err == nil {
+				t.Errorf("newSampler(%q, %v) succeeded, want an error", tt.name, tt.ratio)
+			}
+		})
+	}
+}
+
+func TestSetupExportsSampledSpans(t *testing.T) {
+	sampled := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	unsampled := sampled.WithTraceFlags(0)
+
+	tests := []struct {
+		name    string
+		sampler string
+		ratio   float64
+		parent  trace.SpanContext
+		want    int
+	}{
+		{name: "default", want: 2},
+		{name: "always on", sampler: "always_on", want: 2},
+		{name: "always off", sampler: "always_off", want: 0},
+		{name: "ratio zero", sampler: "traceidratio", ratio: 0, want: 0},
+		{name: "ratio one", sampler: "traceidratio", ratio: 1, want: 2},
+		{name: "parent based follows sampled parent", sampler: "parentbased_always_off", parent: sampled, want: 2},
+		{name: "parent based follows unsampled parent", sampler: "parentbased_always_on", parent: unsampled, want: 0},
+		{name: "always on ignores unsampled parent", sampler: "always_on", parent: unsampled, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := tracingtest.NewCollector(t)
+
+			shutdown, err := Setup(Options{
+				Endpoint:     collector.URL,
+				Sampler:      tt.sampler,
+				SamplerRatio: tt.ratio,
+				ServiceName:  "test-service",
+			})
+
+			if err != nil {
+				t.Fatalf("Setup() error = %v", err)
+			}
+
+			t.Cleanup(func() {
+				otel.SetTracerProvider(noop.NewTracerProvider())
+			})
+
+			ctx := context.Background()
+
+			if tt.parent.IsValid() {
+				ctx = trace.ContextWithRemoteSpanContext(ctx, tt.parent)
+			}
+
+			ctx, parent := Start(ctx, "parent", trace.SpanKindServer)
+			_, child := Start(ctx, "child", trace.SpanKindInternal)
+			child.End()
+			parent.End()
+
+			if err := shutdown(context.Background()); err != nil {
+				t.Fatalf("shutdown error = %v", err)
+			}
+
+			spans := collector.Spans()
+
+			if len(spans) != tt.want {
+				t.Fatalf("exported %d spans, want %d", len(spans), tt.want)
+			}
+
+			if tt.want == 0 {
+				return
+			}
+
+			p, c := collector.Span("parent"), collector.Span("child")
+
+			if p == nil || c == nil {
+				t.Fatalf("exported spans %v, want parent and child", spans)
+			}
+
+			if !bytes.Equal(c.GetParentSpanId(), p.GetSpanId()) || !bytes.Equal(c.GetTraceId(), p.GetTraceId()) {
+				t.Error("child span is not a child of the parent span")
+			}
+
+			if tt.parent.IsValid() {
+				traceID, spanID := tt.parent.TraceID(), tt.parent.SpanID()
+
+				if !bytes.Equal(p.GetTraceId(), traceID[:]) || !bytes.Equal(p.GetParentSpanId(), spanID[:]) {
+					t.Error("parent span does not continue the remote span")
+				}
+			}
+
+			for _, service := range collector.Services() {
+				if service != "test-service" {
+					t.Errorf("service.name = %q, want test-service", service)
+				}
+			}
+		})
+	}
+}
+
+func TestSetupWithoutEndpoint(t *testing.T) {
+	shutdown, err := Setup(Options{Sampler: "bogus"})
+
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown error = %v", err)
+	}
+}
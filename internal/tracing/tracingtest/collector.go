@@ -0,0 +1,113 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package tracingtest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Collector is an in-process OTLP/HTTP receiver, it keeps the spans exported
+// to it with protobuf encoding.
+type Collector struct {
+	*httptest.Server
+	m        sync.Mutex
+	spans    []*tracepb.Span
+	services []string
+}
+
+// NewCollector starts a collector which is closed when the test ends.
+func NewCollector(t testing.TB) *Collector {
+	t.Helper()
+
+	c := &Collector{}
+
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req collectortracepb.ExportTraceServiceRequest
+
+		if err := proto.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c.m.Lock()
+
+		for _, rs := range req.GetResourceSpans() {
+			c.services = append(c.services, stringAttr(rs.GetResource().GetAttributes(), "service.name"))
+
+			for _, ss := range rs.GetScopeSpans() {
+				c.spans = append(c.spans, ss.GetSpans()...)
+			}
+		}
+
+		c.m.Unlock()
+
+		resp, _ := proto.Marshal(&collectortracepb.ExportTraceServiceResponse{})
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(resp)
+	}))
+	t.Cleanup(c.Close)
+
+	return c
+}
+
+// Spans returns the spans received so far.
+func (c *Collector) Spans() []*tracepb.Span {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return append([]*tracepb.Span(nil), c.spans...)
+}
+
+// Span returns the first received span with the name, or nil.
+func (c *Collector) Span(name string) *tracepb.Span {
+	for _, span := range c.Spans() {
+		if span.GetName() == name {
+			return span
+		}
+	}
+
+	return nil
+}
+
+// Services returns the service.name resource attribute of every received
+// batch.
+func (c *Collector) Services() []string {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return append([]string(nil), c.services...)
+}
+
+func stringAttr(attrs []*commonpb.KeyValue, key string) string {
+	for _, kv := range attrs {
+		if kv.GetKey() == key {
+			return kv.GetValue().GetStringValue()
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,174 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Validator is implemented by action requests which check their own fields
+// after decoding.
+type Validator interface {
+	Validate() error
+}
+
+// ActionFunc handles a typed api action. The principal of an authenticated
+// call is available with PrincipalFromContext.
+type ActionFunc[Req any, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// ActionOption configures a registered api action.
+type ActionOption func(a *securedApiAction)
+
+// WithAuth requires a valid token for the action.
+func WithAuth() ActionOption {
+	return func(a *securedApiAction) {
+		a.needAuth = true
+	}
+}
+
+// WithDescription sets the human readable description shown in the catalog.
+func WithDescription(description string) ActionOption {
+	return func(a *securedApiAction) {
+		a.description = description
+	}
+}
+
+// RequireAnyOf requires a valid token with at least one of the groups.
+func RequireAnyOf(groups ...string) ActionOption {
+	return func(a *securedApiAction) {
+		a.needAuth = true
+		a.authz.anyOf = append(a.authz.anyOf, groups...)
+	}
+}
+
+// RequireAllOf requires a valid token with all of the groups.
+func RequireAllOf(groups ...string) ActionOption {
+	return func(a *securedApiAction) {
+		a.needAuth = true
+		a.authz.allOf = append(a.authz.allOf, groups...)
+	}
+}
+
+// RegisterAction registers a typed api action under name. The data of the
+// call is decoded into Req, validated when Req implements Validator, and the
+// returned Resp is sent as JSON. Actions must be registered before the web
+// server is started; registering a name twice panics.
+func RegisterAction[Req any, Resp any](name string, fn ActionFunc[Req, Resp], opts ...ActionOption) {
+	if name == "" {
+		panic("webserver: empty action name")
+	}
+
+	if fn == nil {
+		panic("webserver: nil action " + name)
+	}
+
+	if _, ok := apiActions[name]; ok {
+		panic("webserver: action already registered: " + name)
+	}
+
+	a := securedApiAction{
+		action:   typedAction(fn),
+		reqType:  reflect.TypeOf((*Req)(nil)).Elem(),
+		respType: reflect.TypeOf((*Resp)(nil)).Elem(),
+	}
+
+	for _, opt := range opts {
+		opt(&a)
+	}
+
+	apiActions[name] = a
+}
+
+func typedAction[Req any, Resp any](fn ActionFunc[Req, Resp]) apiAction {
+	return func(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+		var req Req
+
+		if err := decodeActionData(data, &req); err != nil {
+			return func() {
+				sendAPIError(w, r, decodeError(err))
+			}
+		}
+
+		if v, ok := any(&req).(Validator); ok {
+			if err := v.Validate(); err != nil {
+				// validation messages are written for clients
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) {
+					apiErr = NewAPIError(ErrInvalidParameters, err.Error())
+				}
+
+				return func() {
+					sendAPIError(w, r, apiErr)
+				}
+			}
+		}
+
+		resp, err := fn(r.Context(), req)
+
+		if err != nil {
+			return func() {
+				sendAPIError(w, r, err)
+			}
+		}
+
+		return func() {
+			buf, err := json.Marshal(resp)
+
+			if err != nil {
+				sendAPIError(w, r, err)
+				return
+			}
+
+			_, err = w.Write(buf)
+
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error writing response", "error", err)
+			}
+		}
+	}
+}
+
+// decodeActionData decodes the call data except the action name into out,
+// unknown parameters are rejected.
+func decodeActionData(data map[string]interface{}, out interface{}) error {
+	params := make(map[string]interface{}, len(data))
+
+	for key, value := range data {
+		if key != "action" {
+			params[key] = value
+		}
+	}
+
+	return transcode(params, out)
+}
+
+// decodeError converts a parameter decoding error to an api error without
+// leaking decoder internals.
+func decodeError(err error) *APIError {
+	var typeErr *json.UnmarshalTypeError
+
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return NewAPIError(ErrInvalidParameters, fmt.Sprintf("parameter %s has invalid type", typeErr.Field)).
+			WithDetails(map[string]string{"field": typeErr.Field, "expected": typeErr.Type.String()}).
+			WithCause(err)
+	}
+
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+
+		return NewAPIError(ErrInvalidParameters, fmt.Sprintf("unknown parameter %s", field)).
+			WithDetails(map[string]string{"field": field}).
+			WithCause(err)
+	}
+
+	return NewAPIError(ErrInvalidParameters, "invalid parameters").WithCause(err)
+}
@@ -0,0 +1,145 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+	"github.com/kazimsarikaya/go_react_mui/internal/config"
+	"github.com/kazimsarikaya/go_react_mui/internal/logger"
+)
+
+var startTime = time.Now()
+
+// StartAdminServer starts the internal admin listener which serves
+// operational endpoints isolated from the public router. It returns nil when
+// no admin address is configured.
+func StartAdminServer() (*http.Server, error) {
+	addresses := config.GetConfig().GetAdminListen()
+
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	listeners := make([]net.Listener, 0, len(addresses))
+
+	for _, address := range addresses {
+		l, err := listen(address)
+
+		if err != nil {
+			closeListeners(listeners)
+			slog.Error("Error starting admin listener", "error", err)
+			return nil, err
+		}
+
+		slog.Info("Admin listening", "address", address)
+
+		listeners = append(listeners, l)
+	}
+
+	r := newAdminRouter()
+
+	// Recover middleware
+	r.Use(func(next http.Handler) http.Handler {
+		return handlers.RecoveryHandler(
+			handlers.PrintRecoveryStack(true),
+			handlers.RecoveryLogger(logger.DefaultLogger),
+		)(next)
+	})
+
+	srv := &http.Server{
+		ReadTimeout: time.Second * 15,
+		// profiles may stream for longer than the public write timeout
+		WriteTimeout: time.Second * 120,
+		IdleTimeout:  time.Second * 60,
+		Handler:      r,
+		ErrorLog:     logger.DefaultErrorLogger,
+	}
+
+	for _, listener := range listeners {
+		go func() {
+			if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Error starting admin server", "address", listener.Addr(), "error", err)
+			}
+		}()
+	}
+
+	slog.Info("Admin server started")
+
+	return srv, nil
+}
+
+func newAdminRouter() *mux.Router {
+	r := mux.NewRouter()
+
+	handleHealth(r)
+	handleMetrics(r)
+
+	r.HandleFunc("/debug/runtime", RuntimeInfoHandler).Methods(http.MethodGet)
+
+	r.HandleFunc("/debug/pprof/", pprof.Index)
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// named profiles such as heap, goroutine and allocs
+	r.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
+
+	r.NotFoundHandler = http.HandlerFunc(NotFoundHandler)
+
+	return r
+}
+
+// RuntimeInfoHandler reports build and runtime information of the process.
+func RuntimeInfoHandler(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	c := config.GetConfig()
+
+	info := map[string]interface{}{
+		"version":        c.GetVersion(),
+		"build_time":     c.GetBuildTime(),
+		"go_version":     runtime.Version(),
+		"start_time":     startTime.UTC().Format(time.RFC3339),
+		"uptime_seconds": int64(time.Since(startTime).Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"num_cpu":        runtime.NumCPU(),
+		"memory": map[string]interface{}{
+			"alloc_bytes":       m.Alloc,
+			"total_alloc_bytes": m.TotalAlloc,
+			"sys_bytes":         m.Sys,
+			"heap_objects":      m.HeapObjects,
+			"num_gc":            m.NumGC,
+			"pause_total_ns":    m.PauseTotalNs,
+		},
+	}
+
+	buf, err := json.Marshal(info)
+
+	if err != nil {
+		sendAPIError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	_, err = w.Write(buf)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error writing response", "error", err)
+	}
+}
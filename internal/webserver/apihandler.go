@@ -5,89 +5,145 @@
 package webserver
 
 import (
-	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"reflect"
 	"strings"
+	"time"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/logger"
+	"github.com/kazimsarikaya/go_react_mui/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type apiActionResult func()
 type apiAction func(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult
 
 type securedApiAction struct {
-	action   apiAction
-	needAuth bool
-}
-
-var apiActions = map[string]securedApiAction{
-	"get_version": {action: getVersion, needAuth: false},
+	action      apiAction
+	needAuth    bool
+	authz       authorization
+	description string
+	reqType     reflect.Type
+	respType    reflect.Type
 }
 
-func sendError(w http.ResponseWriter, errmsg string, statusCode int) {
-	w.WriteHeader(statusCode)
-	w.Header().Set("Content-Type", "application/json")
-	msg, _ := json.Marshal(map[string]string{"error": errmsg})
-	_, err := w.Write([]byte(msg))
+// apiActions holds registered actions, use RegisterAction to add new ones.
+var apiActions = map[string]securedApiAction{}
 
-	if err != nil {
-		slog.Error("Error writing response", "error", err)
-	}
+func init() {
+	RegisterAction("get_version", getVersion,
+		WithDescription("Returns version, build time and go version of the server"))
+	RegisterAction("list_actions", listActions,
+		WithDescription("Returns the catalog of api actions with JSON schemas of their requests and responses"))
 }
 
 func ApiHandler(w http.ResponseWriter, r *http.Request) {
-	var data map[string]interface{}
+	var payload interface{}
+
+	limits := apiRequestLimits()
+
+	if len(r.URL.RawQuery) > limits.maxQuerySize {
+		sendAPIError(w, r, NewAPIError(ErrPayloadTooLarge, fmt.Sprintf("query string is larger than %d bytes", limits.maxQuerySize)))
+		return
+	}
 
 	// if method get and data parameter exists in query string
 	if (r.Method == "GET" || r.Method == "HEAD") && len(r.URL.Query().Get("data")) > 0 {
-		err := json.Unmarshal([]byte(r.URL.Query().Get("data")), &data)
+		var err error
+
+		payload, err = decodeStrictJSON([]byte(r.URL.Query().Get("data")), limits)
 
 		if err != nil {
-			sendError(w, err.Error(), http.StatusBadRequest)
+			sendAPIError(w, r, jsonDecodeAPIError(err, "data parameter"))
 			return
 		}
 	} else if r.Method == "POST" {
-		if r.Header.Get("Content-Type") == "application/json" {
-			buf, err := io.ReadAll(r.Body)
+		mediaType := requestMediaType(r)
+
+		if mediaType == "application/json" {
+			buf, err := readLimitedBody(w, r, limits)
 
 			if err != nil {
-				sendError(w, err.Error(), http.StatusBadRequest)
+				sendAPIError(w, r, err)
 				return
 			}
 
-			err = json.Unmarshal(buf, &data)
+			payload, err = decodeStrictJSON(buf, limits)
 
 			if err != nil {
-				sendError(w, err.Error(), http.StatusBadRequest)
+				sendAPIError(w, r, jsonDecodeAPIError(err, "request body"))
 				return
 			}
-		} else if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		} else if mediaType == "application/x-www-form-urlencoded" {
+			r.Body = http.MaxBytesReader(w, r.Body, limits.maxBodySize)
+
 			err := r.ParseForm()
 
 			if err != nil {
-				sendError(w, err.Error(), http.StatusBadRequest)
+				var maxErr *http.MaxBytesError
+
+				if errors.As(err, &maxErr) {
+					sendAPIError(w, r, NewAPIError(ErrPayloadTooLarge, fmt.Sprintf("request body is larger than %d bytes", maxErr.Limit)))
+				} else {
+					sendAPIError(w, r, NewAPIError(ErrBadRequest, "form data is invalid").WithCause(err))
+				}
+
 				return
 			}
 
-			data = make(map[string]interface{})
+			if len(r.PostForm) > limits.maxJSONFields {
+				sendAPIError(w, r, NewAPIError(ErrPayloadTooLarge, fmt.Sprintf("form data has more than %d fields", limits.maxJSONFields)))
+				return
+			}
+
+			data := make(map[string]interface{})
 
 			for key, value := range r.PostForm {
+				if len(value) > 1 {
+					sendAPIError(w, r, NewAPIError(ErrBadRequest, fmt.Sprintf("form data has duplicate key %q", key)))
+					return
+				}
+
 				data[key] = value[0]
 			}
+
+			payload = data
 		} else {
-			sendError(w, "Content-Type is not allowed", http.StatusBadRequest)
+			sendAPIError(w, r, NewAPIError(ErrUnsupportedMediaType, "Content-Type is not allowed"))
 			return
 		}
 
 	} else {
-		sendError(w, "method is not allowed", http.StatusMethodNotAllowed)
+		sendError(w, r, "method is not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	switch p := payload.(type) {
+	case map[string]interface{}:
+		dispatchAction(w, r, p)
+	case []interface{}:
+		batchHandler(w, r, p)
+	default:
+		sendError(w, r, "request must be an object or an array", http.StatusBadRequest)
+	}
+}
+
+// dispatchAction checks authorization of the action named in data and calls it.
+func dispatchAction(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	ctx, span := tracing.Start(r.Context(), "api dispatch", trace.SpanKindInternal)
+	defer span.End()
+
+	r = r.WithContext(ctx)
+
 	action, ok := data["action"].(string)
 
 	if !ok {
-		sendError(w, "action parameter is missing", http.StatusBadRequest)
+		sendError(w, r, "action parameter is missing", http.StatusBadRequest)
 		return
 	}
 
@@ -95,52 +151,43 @@ func ApiHandler(w http.ResponseWriter, r *http.Request) {
 
 	// check if action exists
 	if _, ok := apiActions[action]; !ok {
-		sendError(w, "action parameter is invalid", http.StatusBadRequest)
+		sendAPIError(w, r, NewAPIError(ErrUnknownAction, "action parameter is invalid"))
 		return
 	}
 
+	start := time.Now()
+	sr := &statusRecorder{ResponseWriter: w}
+	w = sr
+
+	span.SetAttributes(attribute.String("api.action", action))
+
+	defer func() {
+		observeAction(action, sr.statusCode(), time.Since(start))
+		span.SetAttributes(attribute.Int("http.response.status_code", sr.statusCode()))
+	}()
+
 	// call action
 	if apiActions[action].needAuth {
-		authHeader := r.Header.Get("Authorization")
+		principal, ok := authenticateRequest(w, r)
 
-		if len(authHeader) == 0 {
-			slog.Error("Authorization header is missing")
-			sendError(w, "Authorization header is missing", http.StatusUnauthorized)
+		if !ok {
 			return
 		}
 
-		parts := strings.SplitN(authHeader, " ", 2)
-
-		if len(parts) != 2 {
-			slog.Error("Authorization header is invalid")
-			sendError(w, "Authorization header is invalid", http.StatusUnauthorized)
+		if !apiActions[action].authz.allows(principal.Groups) {
+			slog.ErrorContext(r.Context(), "User is not allowed to call action", "action", action, "username", principal.Username, "groups", principal.Groups)
+			sendError(w, r, "Insufficient permissions", http.StatusForbidden)
 			return
 		}
 
-		tokenType, token := parts[0], parts[1]
-
-		switch tokenType {
-		case "Bearer":
-			valid, err := validateToken(token)
-			if err != nil {
-				slog.Error("Token validation failed", "error", err)
-				sendError(w, "Token validation failed", http.StatusUnauthorized)
-				return
-			} else if valid {
-				slog.Info("Token is valid and user is in 'admins' group.")
-			} else {
-				slog.Error("User is not in 'admins' group")
-				sendError(w, "User is not in 'admins' group", http.StatusUnauthorized)
-				return
-			}
-		default:
-			slog.Error("Authorization header is invalid")
-			sendError(w, "Authorization header is invalid", http.StatusUnauthorized)
-			return
-		}
+		r = r.WithContext(WithPrincipal(r.Context(), principal))
 	}
 
-	result := apiActions[action].action(w, r, data)
+	ctx, actionSpan := tracing.Start(r.Context(), "action "+action, trace.SpanKindInternal,
+		attribute.String("api.action", action))
+	defer actionSpan.End()
+
+	result := apiActions[action].action(w, r.WithContext(ctx), data)
 
 	//chech if w has content type set if not set it to json
 	if w.Header().Get("Content-Type") == "" {
@@ -148,4 +195,52 @@ func ApiHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	result()
+
+	if sr.statusCode() >= http.StatusInternalServerError {
+		actionSpan.SetStatus(codes.Error, http.StatusText(sr.statusCode()))
+	}
+}
+
+// authenticateRequest validates the bearer token of the request and returns
+// the caller. Failures are sent to w.
+func authenticateRequest(w http.ResponseWriter, r *http.Request) (*Principal, bool) {
+	authHeader := r.Header.Get("Authorization")
+
+	if len(authHeader) == 0 {
+		slog.ErrorContext(r.Context(), "Authorization header is missing")
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		sendError(w, r, "Authorization header is missing", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	parts := strings.SplitN(authHeader, " ", 2)
+
+	if len(parts) != 2 {
+		slog.ErrorContext(r.Context(), "Authorization header is invalid")
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		sendError(w, r, "Authorization header is invalid", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	tokenType, token := parts[0], parts[1]
+
+	if tokenType != "Bearer" {
+		slog.ErrorContext(r.Context(), "Authorization header is invalid")
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		sendError(w, r, "Authorization header is invalid", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	principal, err := validateToken(r.Context(), token)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Token validation failed", "error", err)
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		sendError(w, r, "Token validation failed", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	logger.SetRequestUser(r.Context(), principal.Username)
+
+	return principal, true
 }
@@ -6,230 +6,232 @@ package webserver
 
 import (
 	"context"
-	"crypto/rsa"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"math/big"
 	"net/http"
 	"time"
 
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/kazimsarikaya/go_react_mui/internal/config"
+	"github.com/kazimsarikaya/go_react_mui/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	errTokenExpired    = errors.New("token has expired")
+	errInvalidIssuer   = errors.New("invalid issuer")
+	errInvalidAudience = errors.New("invalid audience")
+	errUnknownKid      = errors.New("key not found for kid")
+
+	errKeySetRateLimited = errors.New("key set is not loaded, refetch is rate limited")
+)
+
+// oidcHTTPClient fetches the discovery document and the JWKS. The timeout
+// bounds a fetch even when the caller's context has no deadline.
+var oidcHTTPClient = &http.Client{Timeout: keySetFetchTimeout}
+
 type OIDCConfig struct {
 	JwksURI          string `json:"jwks_uri"`
 	TokenEndpoint    string `json:"token_endpoint"`
 	UserInfoEndpoint string `json:"userinfo_endpoint"`
 }
 
-// JWK represents a JSON Web Key.
-type JWK struct {
-	Kid string `json:"kid"`
-	Alg string `json:"alg"`
-	N   string `json:"n"`
-	E   string `json:"e"`
-}
-
-// JWKS represents a JSON Web Key Set.
-type JWKS struct {
-	Keys []JWK `json:"keys"`
-}
+func fetchOIDCConfig(ctx context.Context, wellKnownURL string) (_ *OIDCConfig, _ time.Duration, err error) {
+	ctx, span := startClientSpan(ctx, "fetch OIDC configuration", wellKnownURL)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
 
-func fetchOIDCConfig(ctx context.Context, wellKnownURL string) (*OIDCConfig, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnownURL, nil)
 
 	if err != nil {
-		slog.Debug("Failed to create request", "error", err)
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		slog.DebugContext(ctx, "Failed to create request", "error", err)
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := oidcHTTPClient.Do(req)
 
 	if err != nil {
-		slog.Debug("Failed to fetch OIDC configuration", "error", err)
-		return nil, fmt.Errorf("failed to fetch OIDC configuration: %w", err)
+		slog.DebugContext(ctx, "Failed to fetch OIDC configuration", "error", err)
+		return nil, 0, fmt.Errorf("failed to fetch OIDC configuration: %w", err)
 	}
 
 	defer resp.Body.Close()
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
 	if resp.StatusCode != http.StatusOK {
-		slog.Debug("Failed to fetch OIDC configuration", "status", resp.Status)
-		return nil, fmt.Errorf("failed to fetch OIDC configuration, status: %s", resp.Status)
+		slog.DebugContext(ctx, "Failed to fetch OIDC configuration", "status", resp.Status)
+		return nil, 0, fmt.Errorf("failed to fetch OIDC configuration, status: %s", resp.Status)
 	}
 
 	var config OIDCConfig
 
 	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
-		slog.Debug("Failed to decode OIDC configuration", "error", err)
-		return nil, fmt.Errorf("failed to decode OIDC configuration: %w", err)
+		slog.DebugContext(ctx, "Failed to decode OIDC configuration", "error", err)
+		return nil, 0, fmt.Errorf("failed to decode OIDC configuration: %w", err)
 	}
 
-	return &config, nil
+	return &config, cacheTTL(resp), nil
 }
 
-// Fetch the JWKS.
-func fetchJWKS(ctx context.Context, jwksURL string) (*JWKS, error) {
+// Fetch the JWKS. The returned duration is how long the response may be cached.
+func fetchJWKS(ctx context.Context, jwksURL string) (_ *JWKS, _ time.Duration, err error) {
+	start := time.Now()
+
+	ctx, span := startClientSpan(ctx, "fetch JWKS", jwksURL)
+
+	defer func() {
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+		}
+
+		jwksFetchDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
 
 	if err != nil {
-		slog.Debug("Failed to create request", "error", err)
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		slog.DebugContext(ctx, "Failed to create request", "error", err)
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := oidcHTTPClient.Do(req)
 
 	if err != nil {
-		slog.Debug("Failed to fetch JWKS", "error", err)
-		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+		slog.DebugContext(ctx, "Failed to fetch JWKS", "error", err)
+		return nil, 0, fmt.Errorf("failed to fetch JWKS: %w", err)
 	}
 
 	defer resp.Body.Close()
 
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
 	if resp.StatusCode != http.StatusOK {
-		slog.Debug("Failed to fetch JWKS", "status", resp.Status)
-		return nil, fmt.Errorf("failed to fetch JWKS, status: %s", resp.Status)
+		slog.DebugContext(ctx, "Failed to fetch JWKS", "status", resp.Status)
+		return nil, 0, fmt.Errorf("failed to fetch JWKS, status: %s", resp.Status)
 	}
 
 	var jwks JWKS
 
 	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
-		slog.Debug("Failed to decode JWKS", "error", err)
-		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
-	}
-
-	return &jwks, nil
-}
-
-// ConvertJWKToPublicKey converts a JWK to an RSA public key.
-func convertJWKToPublicKey(jwk JWK) (*rsa.PublicKey, error) {
-	// Decode the modulus and exponent
-	nBytes, err := base64.RawURLEncoding.DecodeString(jwk.N)
-	if err != nil {
-		slog.Debug("Failed to decode modulus", "error", err)
-		return nil, fmt.Errorf("failed to decode modulus: %w", err)
-	}
-
-	eBytes, err := base64.RawURLEncoding.DecodeString(jwk.E)
-	if err != nil {
-		slog.Debug("Failed to decode exponent", "error", err)
-		return nil, fmt.Errorf("failed to decode exponent: %w", err)
+		slog.DebugContext(ctx, "Failed to decode JWKS", "error", err)
+		return nil, 0, fmt.Errorf("failed to decode JWKS: %w", err)
 	}
 
-	// Convert exponent to integer
-	e := 0
-	for _, b := range eBytes {
-		e = e*256 + int(b)
-	}
-
-	// Construct the RSA public key
-	publicKey := &rsa.PublicKey{
-		N: new(big.Int).SetBytes(nBytes),
-		E: e,
-	}
-
-	return publicKey, nil
+	return &jwks, cacheTTL(resp), nil
 }
 
-// KeyFunc returns the signing key.
-func KeyFunc(ctx context.Context, jwksURL string) jwt.Keyfunc {
+// KeyFunc returns the signing key. Keys are served from the key set cache of
+// the issuer, which is refetched only when it expires or an unknown kid shows up.
+func KeyFunc(ctx context.Context, issuer string) jwt.Keyfunc {
 	return func(token *jwt.Token) (interface{}, error) {
 		// Get the key ID from the token.
 		kid, ok := token.Header["kid"].(string)
 		if !ok {
-			slog.Debug("Missing kid in token header")
+			slog.DebugContext(ctx, "Missing kid in token header")
 			return nil, errors.New("missing kid in token header")
 		}
 
-		// Fetch JWKS.
-		jwks, err := fetchJWKS(ctx, jwksURL)
+		jwk, err := getKeySetCache().lookup(ctx, issuer, kid)
 		if err != nil {
-			slog.Debug("Failed to fetch JWKS", "error", err)
-			return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+			slog.DebugContext(ctx, "Failed to get signing key", "kid", kid, "error", err)
+			return nil, err
 		}
 
-		// Find the corresponding key.
-		for _, jwk := range jwks.Keys {
-			if jwk.Kid == kid {
-				// Convert the JWK to an RSA public key.
-				return convertJWKToPublicKey(jwk)
-			}
+		// The token must be signed with the algorithm the key is meant for.
+		if err := checkJWKAlgorithm(jwk, token.Method.Alg()); err != nil {
+			slog.DebugContext(ctx, "Signing algorithm mismatch", "kid", kid, "error", err)
+			return nil, err
 		}
 
-		slog.Debug("Key not found for kid", "kid", kid)
-		return nil, fmt.Errorf("key not found for kid: %s", kid)
+		// Convert the JWK to a public key.
+		return convertJWKToPublicKey(jwk)
 	}
 }
 
-func validateToken(tokenString string) (bool, error) {
-	ctx := context.Background()
+// validateToken verifies the token signature and standard claims and returns
+// the principal of a valid token.
+func validateToken(ctx context.Context, tokenString string) (_ *Principal, err error) {
+	ctx, span := tracing.Start(ctx, "validate token", trace.SpanKindInternal)
+
+	defer func() {
+		outcome := tokenValidationOutcome(err)
+
+		tokenValidationsTotal.WithLabelValues(outcome).Inc()
+
+		span.SetAttributes(attribute.String("token.outcome", outcome))
+		tracing.RecordError(span, err)
+		span.End()
+	}()
 
 	config := config.GetConfig()
 
 	if config.GetOidcIssuer() == "" {
-		slog.Debug("OIDC issuer not set")
-		return false, errors.New("OIDC issuer not set")
+		slog.DebugContext(ctx, "OIDC issuer not set")
+		return nil, errors.New("OIDC issuer not set")
 	}
 
 	if config.GetOidcAudience() == "" {
-		slog.Debug("OIDC audience not set")
-		return false, errors.New("OIDC audience not set")
-	}
-
-	wellKnownURL := config.GetOidcIssuer() + "/.well-known/openid-configuration"
-
-	// Fetch OIDC configuration
-	oidcConfig, err := fetchOIDCConfig(ctx, wellKnownURL)
-	if err != nil {
-		slog.Debug("Failed to fetch OIDC configuration", "error", err)
-		return false, fmt.Errorf("failed to fetch OIDC configuration: %w", err)
+		slog.DebugContext(ctx, "OIDC audience not set")
+		return nil, errors.New("OIDC audience not set")
 	}
 
 	// Parse the token with the KeyFunc.
-	token, err := jwt.Parse(tokenString, KeyFunc(ctx, oidcConfig.JwksURI))
+	token, err := jwt.Parse(tokenString, KeyFunc(ctx, config.GetOidcIssuer()),
+		jwt.WithValidMethods(supportedSigningAlgorithms))
 	if err != nil {
-		slog.Debug("Token validation failed", "error", err)
-		return false, fmt.Errorf("token validation failed: %w", err)
+		slog.DebugContext(ctx, "Token validation failed", "error", err)
+		return nil, fmt.Errorf("token validation failed: %w", err)
 	}
 
 	// Ensure token is valid
 	if !token.Valid {
-		slog.Debug("Invalid token")
-		return false, errors.New("invalid token")
+		slog.DebugContext(ctx, "Invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	// Extract claims
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		slog.Debug("Failed to parse token claims")
-		return false, errors.New("failed to parse token claims")
+		slog.DebugContext(ctx, "Failed to parse token claims")
+		return nil, errors.New("failed to parse token claims")
 	}
 
 	// Validate expiration (`exp` claim).
 	if exp, ok := claims["exp"].(float64); ok {
 		expirationTime := time.Unix(int64(exp), 0)
 		if time.Now().After(expirationTime) {
-			slog.Debug("Token has expired")
-			return false, fmt.Errorf("token has expired")
+			slog.DebugContext(ctx, "Token has expired")
+			return nil, errTokenExpired
 		}
 	} else {
-		slog.Debug("Missing or invalid exp claim")
-		return false, fmt.Errorf("missing or invalid exp claim")
+		slog.DebugContext(ctx, "Missing or invalid exp claim")
+		return nil, fmt.Errorf("missing or invalid exp claim")
 	}
 
 	// Optional: Validate "nbf" (not before) claim.
 	if nbf, ok := claims["nbf"].(float64); ok {
 		notBeforeTime := time.Unix(int64(nbf), 0)
 		if time.Now().Before(notBeforeTime) {
-			slog.Debug("Token is not yet valid")
-			return false, fmt.Errorf("token is not yet valid")
+			slog.DebugContext(ctx, "Token is not yet valid")
+			return nil, fmt.Errorf("token is not yet valid")
 		}
 	}
 
@@ -237,15 +239,15 @@ func validateToken(tokenString string) (bool, error) {
 	if iat, ok := claims["iat"].(float64); ok {
 		issuedAtTime := time.Unix(int64(iat), 0)
 		if time.Now().Before(issuedAtTime) {
-			slog.Debug("Token issued in the future")
-			return false, fmt.Errorf("token issued in the future")
+			slog.DebugContext(ctx, "Token issued in the future")
+			return nil, fmt.Errorf("token issued in the future")
 		}
 	}
 
 	// Validate claims
 	if claims["iss"] != config.GetOidcIssuer() {
-		slog.Debug("Invalid issuer", "issuer", claims["iss"])
-		return false, errors.New("invalid issuer")
+		slog.DebugContext(ctx, "Invalid issuer", "issuer", claims["iss"])
+		return nil, errInvalidIssuer
 	}
 
 	validAudience := false
@@ -265,8 +267,8 @@ func validateToken(tokenString string) (bool, error) {
 	}
 
 	if !validAudience {
-		slog.Debug("Invalid audience", "audience", claims["aud"])
-		return false, errors.New("invalid audience")
+		slog.DebugContext(ctx, "Invalid audience", "audience", claims["aud"])
+		return nil, errInvalidAudience
 	}
 
 	// Get username
@@ -274,19 +276,21 @@ func validateToken(tokenString string) (bool, error) {
 	username, ok := claims["preferred_username"].(string)
 
 	if !ok {
-		slog.Debug("Username not found")
-		return false, errors.New("username not found")
+		slog.DebugContext(ctx, "Username not found")
+		return nil, errors.New("username not found")
 	}
 
-	// Check if user is in the "admins" group
-	groups, ok := claims["groups"].([]interface{})
-	if !ok {
-		return false, errors.New("groups claim not found or invalid")
-	}
+	// a missing groups claim means no groups, authorization decides
+	groups, _ := claimStrings(claims, config.GetOidcGroupsClaim())
 
-	slog.Debug("Groups", "username", username, "groups", groups)
+	slog.DebugContext(ctx, "Token is valid", "username", username, "groups", groups)
 
-	return true, nil
+	return &Principal{
+		Username: username,
+		Groups:   groups,
+		Claims:   claims,
+		Token:    tokenString,
+	}, nil
 }
 
 // end of file
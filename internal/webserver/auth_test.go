@@ -0,0 +1,234 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/kazimsarikaya/go_react_mui/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	testKid      = "test-key"
+	testAudience = "test-app"
+)
+
+var (
+	testKeyOnce sync.Once
+	testKey     *rsa.PrivateKey
+)
+
+// testSigningKey returns the RSA key of the test IdP, generated once.
+func testSigningKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	testKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+		if err != nil {
+			t.Fatalf("generate key: %v", err)
+		}
+
+		testKey = key
+	})
+
+	return testKey
+}
+
+// testIdP serves the discovery document and the JWKS of testSigningKey.
+type testIdP struct {
+	*httptest.Server
+	jwksHits atomic.Int32
+	// traceparents keeps the last traceparent header received per path
+	traceparents sync.Map
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	key := testSigningKey(t)
+	idp := &testIdP{}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OIDCConfig{JwksURI: idp.URL + "/jwks"})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.jwksHits.Add(1)
+
+		json.NewEncoder(w).Encode(JWKS{Keys: []JWK{{
+			Kid: testKid,
+			Kty: "RSA",
+			Alg: "RS256",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+
+	idp.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idp.traceparents.Store(r.URL.Path, r.Header.Get("traceparent"))
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+// setTestConfig syncs the config with the settings on top of the defaults.
+func setTestConfig(t *testing.T, settings map[string]interface{}) {
+	t.Helper()
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	config.GetConfigBuilder().BuildCommandlineFlags(&cobra.Command{}, &cobra.Command{})
+
+	for key, value := range settings {
+		viper.Set(key, value)
+	}
+
+	if err := config.GetConfigBuilder().SyncConfig(); err != nil {
+		t.Fatalf("sync config: %v", err)
+	}
+}
+
+// setupTestAuth points the config to a test IdP with an empty key set cache.
+func setupTestAuth(t *testing.T) *testIdP {
+	t.Helper()
+
+	idp := newTestIdP(t)
+
+	setTestConfig(t, map[string]interface{}{
+		"oidcIssuer":   idp.URL,
+		"oidcAudience": testAudience,
+	})
+
+	prev := _keySetCache
+	_keySetCache = &keySetCache{}
+
+	t.Cleanup(func() {
+		_keySetCache.stopRefresh()
+		_keySetCache = prev
+	})
+
+	return idp
+}
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	s, err := token.SignedString(testSigningKey(t))
+
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	return s
+}
+
+func TestValidateToken(t *testing.T) {
+	idp := setupTestAuth(t)
+
+	now := time.Now()
+
+	claims := func(override jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":                idp.URL,
+			"aud":                testAudience,
+			"exp":                now.Add(time.Hour).Unix(),
+			"iat":                now.Unix(),
+			"preferred_username": "alice",
+			"groups":             []string{"dev", "ops"},
+		}
+
+		for k, v := range override {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+		ok      bool
+	}{
+		{name: "valid", token: signTestToken(t, jwt.SigningMethodRS256, testKid, claims(nil)), ok: true},
+		{name: "audience list", token: signTestToken(t, jwt.SigningMethodRS256, testKid, claims(jwt.MapClaims{"aud": []string{"other", testAudience}})), ok: true},
+		{name: "expired", token: signTestToken(t, jwt.SigningMethodRS256, testKid, claims(jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()}))},
+		{name: "missing exp", token: signTestToken(t, jwt.SigningMethodRS256, testKid, claims(jwt.MapClaims{"exp": nil}))},
+		{name: "not yet valid", token: signTestToken(t, jwt.SigningMethodRS256, testKid, claims(jwt.MapClaims{"nbf": now.Add(time.Hour).Unix()}))},
+		{name: "wrong issuer", token: signTestToken(t, jwt.SigningMethodRS256, testKid, claims(jwt.MapClaims{"iss": "https://evil.example"})), wantErr: errInvalidIssuer},
+		{name: "wrong audience", token: signTestToken(t, jwt.SigningMethodRS256, testKid, claims(jwt.MapClaims{"aud": "other"})), wantErr: errInvalidAudience},
+		{name: "missing username", token: signTestToken(t, jwt.SigningMethodRS256, testKid, claims(jwt.MapClaims{"preferred_username": nil}))},
+		{name: "unknown kid", token: signTestToken(t, jwt.SigningMethodRS256, "other", claims(nil)), wantErr: errUnknownKid},
+		{name: "algorithm other than the key's", token: signTestToken(t, jwt.SigningMethodPS256, testKid, claims(nil))},
+		{name: "symmetric algorithm", token: func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil))
+			token.Header["kid"] = testKid
+			s, _ := token.SignedString([]byte("secret"))
+			return s
+		}()},
+		{name: "unsigned", token: func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil))
+			token.Header["kid"] = testKid
+			s, _ := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return s
+		}()},
+		{name: "garbage", token: "not.a.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := validateToken(context.Background(), tt.token)
+
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("validateToken() error = %v", err)
+				}
+
+				if principal.Username != "alice" || len(principal.Groups) != 2 || principal.Token != tt.token {
+					t.Errorf("validateToken() principal = %+v", principal)
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatal("validateToken() succeeded, want an error")
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"slices"
+	"strings"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// authorization declares groups or roles required for an api action.
+// The caller must be member of at least one of anyOf (when set) and of
+// all of allOf (when set). An empty authorization only requires a valid token.
+type authorization struct {
+	anyOf []string
+	allOf []string
+}
+
+// allows reports whether the given groups satisfy the authorization.
+func (a authorization) allows(groups []string) bool {
+	if len(a.anyOf) > 0 && !slices.ContainsFunc(a.anyOf, func(g string) bool {
+		return slices.Contains(groups, g)
+	}) {
+		return false
+	}
+
+	for _, g := range a.allOf {
+		if !slices.Contains(groups, g) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// claimStrings extracts a string list claim. Nested claims are addressed with
+// dots, e.g. realm_access.roles. A single string claim is returned as a list
+// with one element.
+func claimStrings(claims jwt.MapClaims, path string) ([]string, bool) {
+	var value interface{} = map[string]interface{}(claims)
+
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = obj[part]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}, true
+	case []interface{}:
+		values := make([]string, 0, len(v))
+
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+
+			values = append(values, s)
+		}
+
+		return values, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,144 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+func init() {
+	RegisterAction("test_admin", func(ctx context.Context, req struct{}) (struct{}, error) {
+		return struct{}{}, nil
+	}, RequireAnyOf("admin", "ops"), RequireAllOf("staff"))
+}
+
+func TestAuthorizationAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		authz  authorization
+		groups []string
+		want   bool
+	}{
+		{"empty requirement", authorization{}, nil, true},
+		{"any of first", authorization{anyOf: []string{"a", "b"}}, []string{"a"}, true},
+		{"any of second", authorization{anyOf: []string{"a", "b"}}, []string{"x", "b"}, true},
+		{"any of none", authorization{anyOf: []string{"a", "b"}}, []string{"x"}, false},
+		{"any of without groups", authorization{anyOf: []string{"a"}}, nil, false},
+		{"all of", authorization{allOf: []string{"a", "b"}}, []string{"b", "a", "x"}, true},
+		{"all of missing one", authorization{allOf: []string{"a", "b"}}, []string{"a"}, false},
+		{"any of and all of", authorization{anyOf: []string{"a", "b"}, allOf: []string{"c"}}, []string{"b", "c"}, true},
+		{"any of without all of", authorization{anyOf: []string{"a", "b"}, allOf: []string{"c"}}, []string{"a"}, false},
+		{"all of without any of", authorization{anyOf: []string{"a", "b"}, allOf: []string{"c"}}, []string{"c"}, false},
+		{"case sensitive", authorization{anyOf: []string{"Admin"}}, []string{"admin"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.authz.allows(tt.groups); got != tt.want {
+				t.Errorf("allows(%v) = %v, want %v", tt.groups, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimStrings(t *testing.T) {
+	claims := jwt.MapClaims{
+		"groups":  []interface{}{"dev", "ops"},
+		"single":  "dev",
+		"mixed":   []interface{}{"dev", 1},
+		"empty":   []interface{}{},
+		"number":  1.0,
+		"nothing": nil,
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"admin"},
+		},
+	}
+
+	tests := []struct {
+		path string
+		want []string
+		ok   bool
+	}{
+		{"groups", []string{"dev", "ops"}, true},
+		{"single", []string{"dev"}, true},
+		{"empty", []string{}, true},
+		{"realm_access.roles", []string{"admin"}, true},
+		{"missing", nil, false},
+		{"realm_access.missing", nil, false},
+		{"groups.roles", nil, false},
+		{"realm_access", nil, false},
+		{"mixed", nil, false},
+		{"number", nil, false},
+		{"nothing", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := claimStrings(claims, tt.path)
+
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("claimStrings(%q) = %v, %v, want %v, %v", tt.path, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDispatchActionAuthorization(t *testing.T) {
+	idp := setupTestAuth(t)
+
+	token := func(groups ...string) string {
+		return signTestToken(t, jwt.SigningMethodRS256, testKid, jwt.MapClaims{
+			"iss":                idp.URL,
+			"aud":                testAudience,
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"preferred_username": "alice",
+			"groups":             groups,
+		})
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"not a bearer token", "Basic YWxpY2U6c2VjcmV0", http.StatusUnauthorized},
+		{"invalid token", "Bearer invalid", http.StatusUnauthorized},
+		{"no groups", "Bearer " + token(), http.StatusForbidden},
+		{"any of without all of", "Bearer " + token("admin"), http.StatusForbidden},
+		{"all of without any of", "Bearer " + token("staff"), http.StatusForbidden},
+		{"allowed", "Bearer " + token("ops", "staff"), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api", strings.NewReader(`{"action":"test_admin"}`))
+			r.Header.Set("Content-Type", "application/json")
+
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			ApiHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/logger"
+)
+
+const (
+	maxBatchSize        = 50
+	maxBatchParallelism = 8
+)
+
+// batchResult is the outcome of one item of a batch call. Result holds the
+// response of a successful action, Error the error of a failed one.
+type batchResult struct {
+	ID     interface{}     `json:"id,omitempty"`
+	Status int             `json:"status"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// bufferedResponseWriter captures the response of a single batch item.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: http.Header{}}
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if b.status == 0 {
+		b.status = statusCode
+	}
+}
+
+// batchHandler runs each item of a batch call as its own action with its own
+// authorization check. Items run sequentially unless the parallel query
+// parameter is set. The response is an array of results in request order.
+func batchHandler(w http.ResponseWriter, r *http.Request, items []interface{}) {
+	if len(items) == 0 {
+		sendError(w, r, "batch is empty", http.StatusBadRequest)
+		return
+	}
+
+	if len(items) > maxBatchSize {
+		sendError(w, r, fmt.Sprintf("batch is too large, maximum is %d items", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	parallel, err := parallelParam(r)
+
+	if err != nil {
+		sendError(w, r, "parallel parameter is invalid", http.StatusBadRequest)
+		return
+	}
+
+	results := make([]batchResult, len(items))
+
+	forEachItem(len(items), parallel, func(i int) {
+		results[i] = runBatchItem(r, items[i])
+	})
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", "application/json")
+
+	buf, err := json.Marshal(results)
+
+	if err != nil {
+		sendAPIError(w, r, err)
+		return
+	}
+
+	_, err = w.Write(buf)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error writing response", "error", err)
+	}
+}
+
+// parallelParam reads the parallel query parameter of batch calls, items run
+// sequentially when it is not set.
+func parallelParam(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("parallel")
+
+	if v == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(v)
+}
+
+// forEachItem calls fn for indexes up to n, concurrently when parallel is set.
+func forEachItem(n int, parallel bool, fn func(i int)) {
+	if !parallel {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+
+		return
+	}
+
+	var wg sync.WaitGroup
+
+	sem := make(chan struct{}, maxBatchParallelism)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			fn(i)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func runBatchItem(r *http.Request, item interface{}) batchResult {
+	data, ok := item.(map[string]interface{})
+
+	if !ok {
+		apiErr := NewAPIError(ErrInvalidParameters, "batch item must be an object")
+		return batchResult{Status: apiErr.Status(), Error: batchItemError(r, apiErr)}
+	}
+
+	// id belongs to the envelope, not to the action parameters
+	params := make(map[string]interface{}, len(data))
+
+	for key, value := range data {
+		if key != "id" {
+			params[key] = value
+		}
+	}
+
+	status, result, errValue := callActionBuffered(r, params)
+
+	return batchResult{
+		ID:     data["id"],
+		Status: status,
+		Result: result,
+		Error:  errValue,
+	}
+}
+
+// batchItemError encodes an error of a batch item like sendAPIError does, so
+// every item of a batch fails with the same shape.
+func batchItemError(r *http.Request, apiErr *APIError) json.RawMessage {
+	apiErr.RequestID = logger.RequestID(r.Context())
+
+	msg, _ := json.Marshal(apiErr)
+
+	return msg
+}
+
+// callActionBuffered dispatches the action in data and captures its response.
+// It returns the status with either the JSON result or the error value sent
+// by the action.
+func callActionBuffered(r *http.Request, data map[string]interface{}) (status int, result json.RawMessage, errValue json.RawMessage) {
+	defer func() {
+		if p := recover(); p != nil {
+			slog.ErrorContext(r.Context(), "Action panicked", "action", data["action"], "panic", p)
+			status = http.StatusInternalServerError
+			result = nil
+			errValue = batchItemError(r, NewAPIError(ErrInternal, "internal error"))
+		}
+	}()
+
+	bw := newBufferedResponseWriter()
+
+	// errors of the item carry the request id of the batch
+	if id := logger.RequestID(r.Context()); id != "" {
+		bw.Header().Set(requestIDHeader, id)
+	}
+
+	dispatchAction(bw, r, data)
+
+	status = bw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	body := bytes.TrimSpace(bw.body.Bytes())
+
+	if status >= http.StatusBadRequest {
+		var e map[string]json.RawMessage
+
+		if err := json.Unmarshal(body, &e); err == nil && e["error"] != nil {
+			errValue = e["error"]
+		} else {
+			errValue = batchItemError(r, NewAPIError(statusErrorCode(status), http.StatusText(status)))
+		}
+
+		return status, nil, errValue
+	}
+
+	if len(body) == 0 {
+		return status, nil, nil
+	}
+
+	if !json.Valid(body) {
+		// actions writing non JSON bodies cannot be embedded
+		result, _ = json.Marshal(string(body))
+		return status, result, nil
+	}
+
+	return status, body, nil
+}
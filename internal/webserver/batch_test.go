@@ -0,0 +1,103 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterAction("test_panic", func(ctx context.Context, req struct{}) (struct{}, error) {
+		panic("broken action")
+	})
+
+	// an action writing a plain text error like http.Error does
+	apiActions["test_plain_error"] = securedApiAction{
+		action: func(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+			return func() {
+				http.Error(w, "teapot", http.StatusTeapot)
+			}
+		},
+		reqType:  reflect.TypeOf(struct{}{}),
+		respType: reflect.TypeOf(struct{}{}),
+	}
+}
+
+func TestApiBatchErrors(t *testing.T) {
+	setTestConfig(t, nil)
+
+	body := `[
+		1,
+		{"id":"panic","action":"test_panic"},
+		{"id":"plain","action":"test_plain_error"},
+		{"id":"conflict","action":"test_conflict"},
+		{"id":"echo","action":"test_echo","value":"hi"}
+	]`
+
+	r := httptest.NewRequest(http.MethodPost, "/api", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(requestIDHeader, "batch-request")
+
+	w := httptest.NewRecorder()
+	requestIDMiddleware(http.HandlerFunc(ApiHandler)).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	var results []batchResult
+
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("decode response: %v: %s", err, w.Body)
+	}
+
+	tests := []struct {
+		status int
+		code   ErrorCode
+	}{
+		{http.StatusBadRequest, ErrInvalidParameters},
+		{http.StatusInternalServerError, ErrInternal},
+		{http.StatusTeapot, ErrBadRequest},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusOK, ""},
+	}
+
+	if len(results) != len(tests) {
+		t.Fatalf("got %d results, want %d: %s", len(results), len(tests), w.Body)
+	}
+
+	for i, tt := range tests {
+		res := results[i]
+
+		if res.Status != tt.status {
+			t.Errorf("item %d: status = %d, want %d", i, res.Status, tt.status)
+		}
+
+		if tt.code == "" {
+			if res.Error != nil {
+				t.Errorf("item %d: unexpected error %s", i, res.Error)
+			}
+
+			continue
+		}
+
+		var apiErr APIError
+
+		if err := json.Unmarshal(res.Error, &apiErr); err != nil {
+			t.Errorf("item %d: error is not an api error: %s", i, res.Error)
+			continue
+		}
+
+		if apiErr.Code != tt.code || apiErr.Message == "" || apiErr.RequestID != "batch-request" {
+			t.Errorf("item %d: error = %+v, want code %s with the request id", i, apiErr, tt.code)
+		}
+	}
+}
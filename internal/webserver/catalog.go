@@ -0,0 +1,80 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// ActionAuth describes the authorization requirements of an action.
+type ActionAuth struct {
+	Required bool     `json:"required"`
+	AnyOf    []string `json:"any_of,omitempty"`
+	AllOf    []string `json:"all_of,omitempty"`
+}
+
+// ActionDescriptor describes a registered api action. The request schema
+// covers the parameters sent next to the action name.
+type ActionDescriptor struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Auth        ActionAuth `json:"auth"`
+	Request     JSONSchema `json:"request,omitempty"`
+	Response    JSONSchema `json:"response,omitempty"`
+}
+
+type ListActionsRequest struct{}
+
+type ListActionsResponse struct {
+	Actions []ActionDescriptor `json:"actions"`
+}
+
+// ActionCatalog returns descriptors of all registered actions sorted by name.
+func ActionCatalog() []ActionDescriptor {
+	catalog := make([]ActionDescriptor, 0, len(apiActions))
+
+	for name, a := range apiActions {
+		d := ActionDescriptor{
+			Name:        name,
+			Description: a.description,
+			Auth: ActionAuth{
+				Required: a.needAuth,
+				AnyOf:    a.authz.anyOf,
+				AllOf:    a.authz.allOf,
+			},
+		}
+
+		if a.reqType != nil {
+			d.Request = generateRequestSchema(a.reqType)
+		}
+
+		if a.respType != nil {
+			d.Response = generateJSONSchema(a.respType)
+		}
+
+		catalog = append(catalog, d)
+	}
+
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].Name < catalog[j].Name
+	})
+
+	return catalog
+}
+
+// WriteActionCatalog writes the action catalog as indented JSON.
+func WriteActionCatalog(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(ListActionsResponse{Actions: ActionCatalog()})
+}
+
+func listActions(ctx context.Context, req ListActionsRequest) (ListActionsResponse, error) {
+	return ListActionsResponse{Actions: ActionCatalog()}, nil
+}
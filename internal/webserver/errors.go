@@ -0,0 +1,181 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// ErrorCode is a stable, machine readable api error code.
+type ErrorCode string
+
+const (
+	ErrBadRequest           ErrorCode = "bad_request"
+	ErrInvalidParameters    ErrorCode = "invalid_parameters"
+	ErrUnknownAction        ErrorCode = "unknown_action"
+	ErrUnauthorized         ErrorCode = "unauthorized"
+	ErrForbidden            ErrorCode = "forbidden"
+	ErrNotFound             ErrorCode = "not_found"
+	ErrMethodNotAllowed     ErrorCode = "method_not_allowed"
+	ErrConflict             ErrorCode = "conflict"
+	ErrPayloadTooLarge      ErrorCode = "payload_too_large"
+	ErrUnsupportedMediaType ErrorCode = "unsupported_media_type"
+	ErrRateLimited          ErrorCode = "rate_limited"
+	ErrInternal             ErrorCode = "internal"
+	ErrUnavailable          ErrorCode = "unavailable"
+)
+
+var errorCodeStatus = map[ErrorCode]int{
+	ErrBadRequest:           http.StatusBadRequest,
+	ErrInvalidParameters:    http.StatusBadRequest,
+	ErrUnknownAction:        http.StatusBadRequest,
+	ErrUnauthorized:         http.StatusUnauthorized,
+	ErrForbidden:            http.StatusForbidden,
+	ErrNotFound:             http.StatusNotFound,
+	ErrMethodNotAllowed:     http.StatusMethodNotAllowed,
+	ErrConflict:             http.StatusConflict,
+	ErrPayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	ErrUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	ErrRateLimited:          http.StatusTooManyRequests,
+	ErrInternal:             http.StatusInternalServerError,
+	ErrUnavailable:          http.StatusServiceUnavailable,
+}
+
+// Status returns the HTTP status code of the error code.
+func (c ErrorCode) Status() int {
+	if status, ok := errorCodeStatus[c]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// APIError is the error sent to api clients. Actions return it to control
+// the code and message clients see; any other error is reported as an
+// internal error and only logged in full.
+type APIError struct {
+	Code      ErrorCode   `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	Retryable bool        `json:"retryable"`
+	RequestID string      `json:"request_id,omitempty"`
+	cause     error
+}
+
+// NewAPIError returns an error with the code and client facing message.
+// Rate limited and unavailable errors are retryable.
+func NewAPIError(code ErrorCode, message string) *APIError {
+	return &APIError{
+		Code:      code,
+		Message:   message,
+		Retryable: code == ErrRateLimited || code == ErrUnavailable,
+	}
+}
+
+func (e *APIError) Error() string {
+	if e.cause != nil {
+		return string(e.Code) + ": " + e.Message + ": " + e.cause.Error()
+	}
+
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.cause
+}
+
+// Status returns the HTTP status code of the error.
+func (e *APIError) Status() int {
+	return e.Code.Status()
+}
+
+// WithDetails returns a copy of the error with client facing details.
+func (e *APIError) WithDetails(details interface{}) *APIError {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// WithCause returns a copy of the error wrapping the cause, which is logged
+// but never sent to clients.
+func (e *APIError) WithCause(err error) *APIError {
+	c := *e
+	c.cause = err
+	return &c
+}
+
+// WithRetryable returns a copy of the error with the retryable flag set.
+func (e *APIError) WithRetryable(retryable bool) *APIError {
+	c := *e
+	c.Retryable = retryable
+	return &c
+}
+
+// toAPIError converts any error to an api error, hiding unknown errors
+// behind a generic internal error.
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	return NewAPIError(ErrInternal, "internal error").WithCause(err)
+}
+
+// statusErrorCode maps a HTTP status code to the closest error code.
+func statusErrorCode(statusCode int) ErrorCode {
+	for code, status := range errorCodeStatus {
+		if status == statusCode && code != ErrInvalidParameters && code != ErrUnknownAction {
+			return code
+		}
+	}
+
+	if statusCode >= http.StatusInternalServerError {
+		return ErrInternal
+	}
+
+	return ErrBadRequest
+}
+
+// sendAPIError logs the error in full with the request's context and sends
+// its sanitized form. The request id is taken from the X-Request-ID response
+// header when set.
+func sendAPIError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := *toAPIError(err)
+
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = w.Header().Get(requestIDHeader)
+	}
+
+	if apiErr.Status() >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "Api error", "code", apiErr.Code, "message", apiErr.Message, "error", apiErr.cause)
+	} else {
+		slog.DebugContext(r.Context(), "Api error", "code", apiErr.Code, "message", apiErr.Message, "error", apiErr.cause)
+	}
+
+	if apiErr.Retryable && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", "1")
+	}
+
+	msg, _ := json.Marshal(map[string]*APIError{"error": &apiErr})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status())
+
+	_, err = w.Write(msg)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error writing response", "error", err)
+	}
+}
+
+// sendError sends an api error with a code derived from the status code.
+func sendError(w http.ResponseWriter, r *http.Request, errmsg string, statusCode int) {
+	sendAPIError(w, r, NewAPIError(statusErrorCode(statusCode), errmsg))
+}
@@ -0,0 +1,175 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorCodeStatus(t *testing.T) {
+	tests := []struct {
+		code ErrorCode
+		want int
+	}{
+		{ErrBadRequest, http.StatusBadRequest},
+		{ErrInvalidParameters, http.StatusBadRequest},
+		{ErrUnknownAction, http.StatusBadRequest},
+		{ErrUnauthorized, http.StatusUnauthorized},
+		{ErrForbidden, http.StatusForbidden},
+		{ErrNotFound, http.StatusNotFound},
+		{ErrMethodNotAllowed, http.StatusMethodNotAllowed},
+		{ErrConflict, http.StatusConflict},
+		{ErrPayloadTooLarge, http.StatusRequestEntityTooLarge},
+		{ErrUnsupportedMediaType, http.StatusUnsupportedMediaType},
+		{ErrRateLimited, http.StatusTooManyRequests},
+		{ErrInternal, http.StatusInternalServerError},
+		{ErrUnavailable, http.StatusServiceUnavailable},
+		{ErrorCode("made_up"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			if got := tt.code.Status(); got != tt.want {
+				t.Errorf("Status() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusErrorCode(t *testing.T) {
+	tests := []struct {
+		status int
+		want   ErrorCode
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusServiceUnavailable, ErrUnavailable},
+		{http.StatusTeapot, ErrBadRequest},
+		{http.StatusBadGateway, ErrInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status), func(t *testing.T) {
+			if got := statusErrorCode(tt.status); got != tt.want {
+				t.Errorf("statusErrorCode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		requestID  string
+		wantStatus int
+		wantCode   ErrorCode
+		wantMsg    string
+		retryable  bool
+	}{
+		{
+			name:       "api error",
+			err:        NewAPIError(ErrNotFound, "pod not found").WithDetails(map[string]string{"name": "web"}),
+			wantStatus: http.StatusNotFound,
+			wantCode:   ErrNotFound,
+			wantMsg:    "pod not found",
+		},
+		{
+			name:       "cause is not sent",
+			err:        NewAPIError(ErrBadRequest, "bad input").WithCause(errors.New("secret detail")),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   ErrBadRequest,
+			wantMsg:    "bad input",
+		},
+		{
+			name:       "wrapped api error",
+			err:        fmt.Errorf("listing pods: %w", NewAPIError(ErrForbidden, "not allowed")),
+			wantStatus: http.StatusForbidden,
+			wantCode:   ErrForbidden,
+			wantMsg:    "not allowed",
+		},
+		{
+			name:       "other errors are hidden",
+			err:        errors.New("secret detail"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   ErrInternal,
+			wantMsg:    "internal error",
+		},
+		{
+			name:       "retryable",
+			err:        NewAPIError(ErrUnavailable, "try later"),
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   ErrUnavailable,
+			wantMsg:    "try later",
+			retryable:  true,
+		},
+		{
+			name:       "not retryable",
+			err:        NewAPIError(ErrRateLimited, "slow down").WithRetryable(false),
+			wantStatus: http.StatusTooManyRequests,
+			wantCode:   ErrRateLimited,
+			wantMsg:    "slow down",
+		},
+		{
+			name:       "request id",
+			err:        NewAPIError(ErrConflict, "exists"),
+			requestID:  "req-1",
+			wantStatus: http.StatusConflict,
+			wantCode:   ErrConflict,
+			wantMsg:    "exists",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			if tt.requestID != "" {
+				w.Header().Set(requestIDHeader, tt.requestID)
+			}
+
+			sendAPIError(w, httptest.NewRequest(http.MethodGet, "/api", nil), tt.err)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+
+			if strings.Contains(w.Body.String(), "secret detail") {
+				t.Errorf("body leaks the cause: %s", w.Body)
+			}
+
+			var body struct {
+				Error APIError `json:"error"`
+			}
+
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body %s: %v", w.Body, err)
+			}
+
+			got := body.Error
+
+			if got.Code != tt.wantCode || got.Message != tt.wantMsg || got.Retryable != tt.retryable || got.RequestID != tt.requestID {
+				t.Errorf("error = %+v", got)
+			}
+
+			if retryAfter := w.Header().Get("Retry-After"); (retryAfter != "") != tt.retryable {
+				t.Errorf("Retry-After = %q, retryable %v", retryAfter, tt.retryable)
+			}
+		})
+	}
+}
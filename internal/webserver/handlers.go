@@ -13,7 +13,7 @@ import (
 func SPAHandler(w http.ResponseWriter, r *http.Request) {
 	// disable other than GET and HEAD methods
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
-		sendError(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		sendError(w, r, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -65,7 +65,7 @@ func SPAHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
-	sendError(w, "Not Found", http.StatusNotFound)
+	sendError(w, r, "Not Found", http.StatusNotFound)
 }
 
 // end of file
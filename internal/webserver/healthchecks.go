@@ -0,0 +1,57 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kazimsarikaya/go_react_mui/internal/config"
+	"github.com/kazimsarikaya/go_react_mui/internal/health"
+	"github.com/kazimsarikaya/go_react_mui/internal/kube"
+)
+
+const (
+	oidcCheckTimeout   = 10 * time.Second
+	kubeCheckTimeout   = 5 * time.Second
+	staticCheckTimeout = 1 * time.Second
+)
+
+func handleHealth(r *mux.Router) {
+	r.HandleFunc("/healthz", health.LivenessHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/readyz", health.ReadinessHandler).Methods(http.MethodGet, http.MethodHead)
+}
+
+// registerHealthChecks registers readiness checks of the dependencies the
+// server is configured with.
+func registerHealthChecks() {
+	c := config.GetConfig()
+
+	health.RegisterReadiness("static", staticCheckTimeout, checkStaticAssets)
+
+	if issuer := c.GetOidcIssuer(); issuer != "" {
+		health.RegisterReadiness("oidc", oidcCheckTimeout, func(ctx context.Context) error {
+			return getKeySetCache().check(ctx, issuer)
+		})
+	}
+
+	if client := kube.GetClient(); client != nil {
+		health.RegisterReadiness("kubernetes", kubeCheckTimeout, client.Ping)
+	}
+}
+
+// checkStaticAssets checks the frontend entry point can be served.
+func checkStaticAssets(ctx context.Context) error {
+	f, err := staticFS.Open("/index.html.gz")
+
+	if err != nil {
+		return fmt.Errorf("frontend is not available: %w", err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,203 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/config"
+)
+
+// requestLimits bounds the size and shape of api requests.
+type requestLimits struct {
+	maxBodySize   int64
+	maxQuerySize  int
+	maxJSONDepth  int
+	maxJSONFields int
+}
+
+// apiRequestLimits returns the configured limits.
+func apiRequestLimits() requestLimits {
+	c := config.GetConfig()
+
+	return requestLimits{
+		maxBodySize:   c.GetApiMaxBodySize(),
+		maxQuerySize:  c.GetApiMaxQuerySize(),
+		maxJSONDepth:  c.GetApiMaxJSONDepth(),
+		maxJSONFields: c.GetApiMaxJSONFields(),
+	}
+}
+
+// strictJSONError is a decoding error with a message safe to send to clients.
+type strictJSONError struct {
+	msg      string
+	tooLarge bool
+}
+
+func (e *strictJSONError) Error() string {
+	return e.msg
+}
+
+// readLimitedBody reads the request body up to the limit.
+func readLimitedBody(w http.ResponseWriter, r *http.Request, limits requestLimits) ([]byte, error) {
+	buf, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limits.maxBodySize))
+
+	if err != nil {
+		var maxErr *http.MaxBytesError
+
+		if errors.As(err, &maxErr) {
+			return nil, NewAPIError(ErrPayloadTooLarge, fmt.Sprintf("request body is larger than %d bytes", maxErr.Limit))
+		}
+
+		return nil, NewAPIError(ErrBadRequest, "request body cannot be read").WithCause(err)
+	}
+
+	return buf, nil
+}
+
+// decodeStrictJSON decodes a single JSON value rejecting duplicate object
+// keys, trailing data and values exceeding the depth and field limits.
+func decodeStrictJSON(buf []byte, limits requestLimits) (interface{}, error) {
+	d := &strictDecoder{
+		dec:    json.NewDecoder(bytes.NewReader(buf)),
+		limits: limits,
+	}
+
+	v, err := d.value(0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := d.dec.Token(); err != io.EOF {
+		return nil, &strictJSONError{msg: "unexpected data after JSON value"}
+	}
+
+	return v, nil
+}
+
+// jsonDecodeAPIError converts a decodeStrictJSON error to an api error.
+func jsonDecodeAPIError(err error, what string) *APIError {
+	var strictErr *strictJSONError
+
+	if errors.As(err, &strictErr) {
+		if strictErr.tooLarge {
+			return NewAPIError(ErrPayloadTooLarge, what+": "+strictErr.msg)
+		}
+
+		return NewAPIError(ErrBadRequest, what+": "+strictErr.msg)
+	}
+
+	return NewAPIError(ErrBadRequest, what+" is not valid JSON").WithCause(err)
+}
+
+type strictDecoder struct {
+	dec    *json.Decoder
+	limits requestLimits
+	fields int
+}
+
+func (d *strictDecoder) countField() error {
+	d.fields++
+
+	if d.fields > d.limits.maxJSONFields {
+		return &strictJSONError{msg: fmt.Sprintf("more than %d fields", d.limits.maxJSONFields), tooLarge: true}
+	}
+
+	return nil
+}
+
+func (d *strictDecoder) value(depth int) (interface{}, error) {
+	tok, err := d.dec.Token()
+
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+
+	if !ok {
+		// string, float64, bool or nil
+		return tok, nil
+	}
+
+	if depth >= d.limits.maxJSONDepth {
+		return nil, &strictJSONError{msg: fmt.Sprintf("nesting is deeper than %d", d.limits.maxJSONDepth), tooLarge: true}
+	}
+
+	switch delim {
+	case '{':
+		obj := map[string]interface{}{}
+
+		for d.dec.More() {
+			keyTok, err := d.dec.Token()
+
+			if err != nil {
+				return nil, err
+			}
+
+			key, ok := keyTok.(string)
+
+			if !ok {
+				return nil, &strictJSONError{msg: "object key is not a string"}
+			}
+
+			if _, dup := obj[key]; dup {
+				return nil, &strictJSONError{msg: fmt.Sprintf("duplicate key %q", key)}
+			}
+
+			if err := d.countField(); err != nil {
+				return nil, err
+			}
+
+			v, err := d.value(depth + 1)
+
+			if err != nil {
+				return nil, err
+			}
+
+			obj[key] = v
+		}
+
+		if _, err := d.dec.Token(); err != nil {
+			return nil, err
+		}
+
+		return obj, nil
+	case '[':
+		arr := []interface{}{}
+
+		for d.dec.More() {
+			if err := d.countField(); err != nil {
+				return nil, err
+			}
+
+			v, err := d.value(depth + 1)
+
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, v)
+		}
+
+		if _, err := d.dec.Token(); err != nil {
+			return nil, err
+		}
+
+		return arr, nil
+	}
+
+	return nil, &strictJSONError{msg: fmt.Sprintf("unexpected delimiter %s", delim)}
+}
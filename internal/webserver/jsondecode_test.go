@@ -0,0 +1,116 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeStrictJSON(t *testing.T) {
+	limits := requestLimits{maxJSONDepth: 3, maxJSONFields: 4}
+
+	tests := []struct {
+		name string
+		in   string
+		want interface{}
+		code ErrorCode
+	}{
+		{name: "object", in: `{"a":1,"b":"x"}`, want: map[string]interface{}{"a": 1.0, "b": "x"}},
+		{name: "array", in: `[true,null]`, want: []interface{}{true, nil}},
+		{name: "scalar", in: ` "x" `, want: "x"},
+		{name: "empty object", in: `{}`, want: map[string]interface{}{}},
+		{name: "depth at limit", in: `{"a":{"b":[1]}}`, want: map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1.0}}}},
+		{name: "depth over limit", in: `{"a":{"b":{"c":{}}}}`, code: ErrPayloadTooLarge},
+		{name: "fields counted across levels", in: `{"a":1,"b":[1,2,3]}`, code: ErrPayloadTooLarge},
+		{name: "fields over limit", in: `[1,2,3,4,5]`, code: ErrPayloadTooLarge},
+		{name: "duplicate key", in: `{"a":1,"a":2}`, code: ErrBadRequest},
+		{name: "nested duplicate key", in: `{"a":{"b":1,"b":1}}`, code: ErrBadRequest},
+		{name: "trailing value", in: `{} {}`, code: ErrBadRequest},
+		{name: "trailing garbage", in: `{}x`, code: ErrBadRequest},
+		{name: "truncated", in: `{"a":`, code: ErrBadRequest},
+		{name: "empty", in: ``, code: ErrBadRequest},
+		{name: "invalid", in: `{a:1}`, code: ErrBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeStrictJSON([]byte(tt.in), limits)
+
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("decodeStrictJSON() error = %v", err)
+				}
+
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("decodeStrictJSON() = %#v, want %#v", got, tt.want)
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("decodeStrictJSON() = %#v, want an error", got)
+			}
+
+			if apiErr := jsonDecodeAPIError(err, "body"); apiErr.Code != tt.code {
+				t.Errorf("error code = %s, want %s (%v)", apiErr.Code, tt.code, err)
+			}
+		})
+	}
+}
+
+func TestApiHandlerRequests(t *testing.T) {
+	setTestConfig(t, map[string]interface{}{
+		"apiMaxBodySize":  128,
+		"apiMaxQuerySize": 96,
+	})
+
+	echo := `{"action":"test_echo","value":"hi"}`
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"json", http.MethodPost, "/api", "application/json", echo, http.StatusOK},
+		{"json with charset", http.MethodPost, "/api", "application/json; charset=utf-8", echo, http.StatusOK},
+		{"json with upper case type", http.MethodPost, "/api", "Application/JSON", echo, http.StatusOK},
+		{"form", http.MethodPost, "/api", "application/x-www-form-urlencoded; charset=utf-8", "action=test_echo&value=hi", http.StatusOK},
+		{"form duplicate key", http.MethodPost, "/api", "application/x-www-form-urlencoded", "action=test_echo&value=a&value=b", http.StatusBadRequest},
+		{"query", http.MethodGet, "/api?data=" + url.QueryEscape(echo), "", "", http.StatusOK},
+		{"query too large", http.MethodGet, "/api?data=" + url.QueryEscape(`{"action":"test_echo","value":"`+strings.Repeat("x", 96)+`"}`), "", "", http.StatusRequestEntityTooLarge},
+		{"body too large", http.MethodPost, "/api", "application/json", `{"action":"test_echo","value":"` + strings.Repeat("x", 128) + `"}`, http.StatusRequestEntityTooLarge},
+		{"text content type", http.MethodPost, "/api", "text/plain", echo, http.StatusUnsupportedMediaType},
+		{"invalid content type", http.MethodPost, "/api", "application/json; =", echo, http.StatusUnsupportedMediaType},
+		{"unknown parameter", http.MethodPost, "/api", "application/json", `{"action":"test_echo","other":1}`, http.StatusBadRequest},
+		{"scalar body", http.MethodPost, "/api", "application/json", `1`, http.StatusBadRequest},
+		{"put", http.MethodPut, "/api", "application/json", echo, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			w := httptest.NewRecorder()
+			ApiHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
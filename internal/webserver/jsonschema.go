@@ -0,0 +1,238 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// JSONSchema is a JSON Schema (draft 2020-12) document.
+type JSONSchema map[string]interface{}
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaDefName makes type names safe to use in $ref pointers.
+var schemaDefName = strings.NewReplacer("/", "_", "~", "_", "[", "_", "]", "_", ",", "_", " ", "")
+
+// schemaGenerator builds schemas following encoding/json rules. Named struct
+// types below the root are emitted once into $defs and referenced.
+type schemaGenerator struct {
+	defs map[string]JSONSchema
+	seen map[reflect.Type]string
+	// request leaves out required, missing parameters decode to zero values
+	request bool
+}
+
+// generateJSONSchema returns the schema of values of type t as encoded by
+// encoding/json. Fields without omitempty are always encoded and required.
+func generateJSONSchema(t reflect.Type) JSONSchema {
+	return newSchemaGenerator(false).generate(t)
+}
+
+// generateRequestSchema returns the schema of action parameters decoded into
+// type t. No parameter is required, a missing one decodes to its zero value
+// and actions check their parameters with Validator.
+func generateRequestSchema(t reflect.Type) JSONSchema {
+	return newSchemaGenerator(true).generate(t)
+}
+
+func newSchemaGenerator(request bool) *schemaGenerator {
+	return &schemaGenerator{
+		defs:    map[string]JSONSchema{},
+		seen:    map[reflect.Type]string{},
+		request: request,
+	}
+}
+
+func (g *schemaGenerator) generate(t reflect.Type) JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var schema JSONSchema
+
+	if t.Kind() == reflect.Struct && t.Name() != "" && !isSpecialType(t) {
+		// root struct is inlined so consumers see its properties directly
+		schema = g.structSchema(t)
+		schema["title"] = t.Name()
+	} else {
+		schema = g.schema(t)
+	}
+
+	schema["$schema"] = jsonSchemaDialect
+
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+
+	return schema
+}
+
+func isSpecialType(t reflect.Type) bool {
+	return t == timeType || t == rawMessageType ||
+		t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return JSONSchema{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return JSONSchema{}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return JSONSchema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return JSONSchema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return JSONSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return JSONSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"type": "number"}
+	case reflect.String:
+		return JSONSchema{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return JSONSchema{"type": "string", "contentEncoding": "base64"}
+		}
+
+		return JSONSchema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Array:
+		return JSONSchema{
+			"type":     "array",
+			"items":    g.schema(t.Elem()),
+			"minItems": t.Len(),
+			"maxItems": t.Len(),
+		}
+	case reflect.Map:
+		return JSONSchema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+
+		return JSONSchema{"$ref": "#/$defs/" + g.define(t)}
+	}
+
+	// interfaces and anything else accept any value
+	return JSONSchema{}
+}
+
+// define adds the named struct type to $defs and returns its key.
+func (g *schemaGenerator) define(t reflect.Type) string {
+	if name, ok := g.seen[t]; ok {
+		return name
+	}
+
+	name := schemaDefName.Replace(t.Name())
+
+	if _, ok := g.defs[name]; ok {
+		// same name from another package
+		name = schemaDefName.Replace(t.PkgPath() + "." + t.Name())
+	}
+
+	g.seen[t] = name
+	// placeholder breaks recursion
+	g.defs[name] = JSONSchema{}
+
+	schema := g.structSchema(t)
+	schema["title"] = t.Name()
+	g.defs[name] = schema
+
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) JSONSchema {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	g.addFields(t, properties, &required)
+
+	schema := JSONSchema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if len(required) > 0 && !g.request {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		// untagged embedded structs are flattened like encoding/json does
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.addFields(ft, properties, required)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		var schema JSONSchema
+
+		if strings.Contains(","+opts+",", ",string,") {
+			schema = JSONSchema{"type": "string"}
+		} else {
+			schema = g.schema(f.Type)
+		}
+
+		if desc := f.Tag.Get("description"); desc != "" {
+			schema["description"] = desc
+		}
+
+		properties[name] = schema
+
+		optional := strings.Contains(","+opts+",", ",omitempty,") ||
+			strings.Contains(","+opts+",", ",omitzero,") ||
+			f.Type.Kind() == reflect.Pointer
+
+		if !optional {
+			*required = append(*required, name)
+		}
+	}
+}
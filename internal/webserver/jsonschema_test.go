@@ -0,0 +1,58 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+type schemaTestItem struct {
+	Name string `json:"name"`
+}
+
+type schemaTestParams struct {
+	Name     string           `json:"name"`
+	Limit    int              `json:"limit,omitempty"`
+	Selector *string          `json:"selector"`
+	Items    []schemaTestItem `json:"items"`
+}
+
+func TestGenerateJSONSchemaRequired(t *testing.T) {
+	t.Run("response", func(t *testing.T) {
+		schema := generateJSONSchema(reflect.TypeOf(schemaTestParams{}))
+
+		if got, want := schema["required"], []string{"name", "items"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("required = %v, want %v", got, want)
+		}
+
+		item := schema["$defs"].(map[string]JSONSchema)["schemaTestItem"]
+
+		if got, want := item["required"], []string{"name"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("required of item = %v, want %v", got, want)
+		}
+	})
+
+	// decoding does not enforce required parameters, the schema must not claim it
+	t.Run("request", func(t *testing.T) {
+		schema := generateRequestSchema(reflect.TypeOf(schemaTestParams{}))
+
+		if _, ok := schema["required"]; ok {
+			t.Errorf("request schema has required: %v", schema["required"])
+		}
+
+		item := schema["$defs"].(map[string]JSONSchema)["schemaTestItem"]
+
+		if _, ok := item["required"]; ok {
+			t.Errorf("request schema of item has required: %v", item["required"])
+		}
+
+		var params schemaTestParams
+
+		if err := decodeActionData(map[string]interface{}{"action": "test"}, &params); err != nil {
+			t.Errorf("decoding without parameters: %v", err)
+		}
+	})
+}
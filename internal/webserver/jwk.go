@@ -0,0 +1,196 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+)
+
+// JWK represents a JSON Web Key.
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS represents a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// supportedSigningAlgorithms lists token algorithms accepted by validateToken.
+var supportedSigningAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// ecCurves maps JWK curve names to curves and the ES algorithm using them.
+var ecCurves = map[string]struct {
+	curve elliptic.Curve
+	alg   string
+}{
+	"P-256": {curve: elliptic.P256(), alg: "ES256"},
+	"P-384": {curve: elliptic.P384(), alg: "ES384"},
+	"P-521": {curve: elliptic.P521(), alg: "ES512"},
+}
+
+// checkJWKAlgorithm ensures a token signed with alg may be verified by the key.
+// When the key declares an algorithm it must match exactly, otherwise the
+// algorithm must belong to the key type (and curve).
+func checkJWKAlgorithm(jwk JWK, alg string) error {
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return fmt.Errorf("key %s is not a signing key, use: %s", jwk.Kid, jwk.Use)
+	}
+
+	if jwk.Alg != "" && jwk.Alg != alg {
+		return fmt.Errorf("token algorithm %s does not match key algorithm %s", alg, jwk.Alg)
+	}
+
+	switch jwk.Kty {
+	case "RSA", "":
+		if strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS") {
+			return nil
+		}
+	case "EC":
+		if c, ok := ecCurves[jwk.Crv]; ok && c.alg == alg {
+			return nil
+		}
+	case "OKP":
+		if jwk.Crv == "Ed25519" && alg == "EdDSA" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("token algorithm %s cannot be used with key type %s", alg, jwk.Kty)
+}
+
+// convertJWKToPublicKey converts a JWK to an RSA, ECDSA or Ed25519 public key.
+func convertJWKToPublicKey(jwk JWK) (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA", "":
+		return convertRSAJWK(jwk)
+	case "EC":
+		return convertECJWK(jwk)
+	case "OKP":
+		return convertOKPJWK(jwk)
+	}
+
+	slog.Debug("Unsupported key type", "kty", jwk.Kty)
+	return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+}
+
+func convertRSAJWK(jwk JWK) (*rsa.PublicKey, error) {
+	// Decode the modulus and exponent
+	nBytes, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		slog.Debug("Failed to decode modulus", "error", err)
+		return nil, fmt.Errorf("failed to decode modulus: %w", err)
+	}
+
+	eBytes, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		slog.Debug("Failed to decode exponent", "error", err)
+		return nil, fmt.Errorf("failed to decode exponent: %w", err)
+	}
+
+	if len(nBytes) == 0 || len(eBytes) == 0 || len(eBytes) > 4 {
+		slog.Debug("Invalid RSA key parameters")
+		return nil, errors.New("invalid RSA key parameters")
+	}
+
+	// Convert exponent to integer
+	e := 0
+	for _, b := range eBytes {
+		e = e*256 + int(b)
+	}
+
+	// Construct the RSA public key
+	publicKey := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: e,
+	}
+
+	return publicKey, nil
+}
+
+func convertECJWK(jwk JWK) (*ecdsa.PublicKey, error) {
+	c, ok := ecCurves[jwk.Crv]
+	if !ok {
+		slog.Debug("Unsupported curve", "crv", jwk.Crv)
+		return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		slog.Debug("Failed to decode x coordinate", "error", err)
+		return nil, fmt.Errorf("failed to decode x coordinate: %w", err)
+	}
+
+	yBytes, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		slog.Debug("Failed to decode y coordinate", "error", err)
+		return nil, fmt.Errorf("failed to decode y coordinate: %w", err)
+	}
+
+	size := (c.curve.Params().BitSize + 7) / 8
+	if len(xBytes) != size || len(yBytes) != size {
+		slog.Debug("Invalid coordinate length", "crv", jwk.Crv)
+		return nil, fmt.Errorf("invalid coordinate length for curve %s", jwk.Crv)
+	}
+
+	publicKey := &ecdsa.PublicKey{
+		Curve: c.curve,
+		X:     new(big.Int).SetBytes(xBytes),
+		Y:     new(big.Int).SetBytes(yBytes),
+	}
+
+	// ECDH conversion validates that the point is on the curve
+	if _, err := publicKey.ECDH(); err != nil {
+		slog.Debug("Invalid EC public key", "error", err)
+		return nil, fmt.Errorf("invalid EC public key: %w", err)
+	}
+
+	return publicKey, nil
+}
+
+func convertOKPJWK(jwk JWK) (ed25519.PublicKey, error) {
+	if jwk.Crv != "Ed25519" {
+		slog.Debug("Unsupported curve", "crv", jwk.Crv)
+		return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		slog.Debug("Failed to decode public key", "error", err)
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+
+	if len(xBytes) != ed25519.PublicKeySize {
+		slog.Debug("Invalid Ed25519 public key length", "length", len(xBytes))
+		return nil, errors.New("invalid Ed25519 public key length")
+	}
+
+	return ed25519.PublicKey(xBytes), nil
+}
@@ -0,0 +1,143 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestCheckJWKAlgorithm(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+		alg  string
+		ok   bool
+	}{
+		{"rsa RS256", JWK{Kty: "RSA"}, "RS256", true},
+		{"rsa PS512", JWK{Kty: "RSA"}, "PS512", true},
+		{"rsa without kty", JWK{}, "RS384", true},
+		{"rsa ES256", JWK{Kty: "RSA"}, "ES256", false},
+		{"rsa declared alg", JWK{Kty: "RSA", Alg: "RS256"}, "RS256", true},
+		{"rsa declared alg mismatch", JWK{Kty: "RSA", Alg: "RS256"}, "PS256", false},
+		{"encryption key", JWK{Kty: "RSA", Use: "enc"}, "RS256", false},
+		{"signing key", JWK{Kty: "RSA", Use: "sig"}, "RS256", true},
+		{"ec P-256 ES256", JWK{Kty: "EC", Crv: "P-256"}, "ES256", true},
+		{"ec P-384 ES384", JWK{Kty: "EC", Crv: "P-384"}, "ES384", true},
+		{"ec P-521 ES512", JWK{Kty: "EC", Crv: "P-521"}, "ES512", true},
+		{"ec curve mismatch", JWK{Kty: "EC", Crv: "P-256"}, "ES384", false},
+		{"ec unknown curve", JWK{Kty: "EC", Crv: "secp256k1"}, "ES256", false},
+		{"ec RS256", JWK{Kty: "EC", Crv: "P-256"}, "RS256", false},
+		{"okp EdDSA", JWK{Kty: "OKP", Crv: "Ed25519"}, "EdDSA", true},
+		{"okp Ed448", JWK{Kty: "OKP", Crv: "Ed448"}, "EdDSA", false},
+		{"okp RS256", JWK{Kty: "OKP", Crv: "Ed25519"}, "RS256", false},
+		{"symmetric key", JWK{Kty: "oct"}, "HS256", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkJWKAlgorithm(tt.jwk, tt.alg)
+
+			if tt.ok && err != nil {
+				t.Errorf("checkJWKAlgorithm() error = %v", err)
+			}
+
+			if !tt.ok && err == nil {
+				t.Error("checkJWKAlgorithm() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestConvertJWKToPublicKey(t *testing.T) {
+	rsaKey := testSigningKey(t)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("generate EC key: %v", err)
+	}
+
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("generate Ed25519 key: %v", err)
+	}
+
+	ecX, ecY := make([]byte, 32), make([]byte, 32)
+	ecKey.X.FillBytes(ecX)
+	ecKey.Y.FillBytes(ecY)
+
+	// a point which is not on the curve
+	offY := make([]byte, 32)
+	new(big.Int).Add(ecKey.Y, big.NewInt(1)).FillBytes(offY)
+
+	rsaN := b64(rsaKey.N.Bytes())
+	rsaE := b64(big.NewInt(int64(rsaKey.E)).Bytes())
+
+	tests := []struct {
+		name string
+		jwk  JWK
+		ok   bool
+	}{
+		{"rsa", JWK{Kty: "RSA", N: rsaN, E: rsaE}, true},
+		{"rsa missing modulus", JWK{Kty: "RSA", E: rsaE}, false},
+		{"rsa missing exponent", JWK{Kty: "RSA", N: rsaN}, false},
+		{"rsa oversized exponent", JWK{Kty: "RSA", N: rsaN, E: b64([]byte{1, 0, 0, 0, 1})}, false},
+		{"rsa padded base64", JWK{Kty: "RSA", N: rsaN + "=", E: rsaE}, false},
+		{"ec", JWK{Kty: "EC", Crv: "P-256", X: b64(ecX), Y: b64(ecY)}, true},
+		{"ec point off the curve", JWK{Kty: "EC", Crv: "P-256", X: b64(ecX), Y: b64(offY)}, false},
+		{"ec short coordinate", JWK{Kty: "EC", Crv: "P-256", X: b64(ecX[1:]), Y: b64(ecY)}, false},
+		{"ec curve of other size", JWK{Kty: "EC", Crv: "P-384", X: b64(ecX), Y: b64(ecY)}, false},
+		{"ec unknown curve", JWK{Kty: "EC", Crv: "P-192", X: b64(ecX), Y: b64(ecY)}, false},
+		{"okp", JWK{Kty: "OKP", Crv: "Ed25519", X: b64(edKey)}, true},
+		{"okp short key", JWK{Kty: "OKP", Crv: "Ed25519", X: b64(edKey[1:])}, false},
+		{"okp X25519", JWK{Kty: "OKP", Crv: "X25519", X: b64(edKey)}, false},
+		{"symmetric key", JWK{Kty: "oct"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := convertJWKToPublicKey(tt.jwk)
+
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("convertJWKToPublicKey() = %T, want an error", key)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("convertJWKToPublicKey() error = %v", err)
+			}
+
+			var want interface{ Equal(crypto.PublicKey) bool }
+
+			switch tt.jwk.Kty {
+			case "RSA":
+				want = &rsaKey.PublicKey
+			case "EC":
+				want = &ecKey.PublicKey
+			case "OKP":
+				want = edKey
+			}
+
+			if !want.Equal(key) {
+				t.Errorf("convertJWKToPublicKey() = %v, want %v", key, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	keySetDefaultTTL      = 1 * time.Hour
+	keySetMinTTL          = 1 * time.Minute
+	keySetMaxTTL          = 24 * time.Hour
+	keySetKidMissInterval = 30 * time.Second
+	keySetFetchTimeout    = 10 * time.Second
+	// how long cached keys keep an instance ready while refreshes fail
+	keySetMaxStale = 24 * time.Hour
+)
+
+// keySetCache keeps the OIDC discovery document and the JWKS of the issuer
+// in memory. Both are loaded once, refreshed in background before they
+// expire and refetched early only when a token arrives with an unknown kid.
+type keySetCache struct {
+	m            sync.RWMutex
+	fetchM       sync.Mutex
+	issuer       string
+	oidcConfig   *OIDCConfig
+	oidcExpires  time.Time
+	keys         map[string]JWK
+	keysExpires  time.Time
+	keysLoaded   time.Time
+	lastFetch    time.Time
+	lastErr      error
+	refreshTimer *time.Timer
+}
+
+var (
+	_keySetCache = &keySetCache{}
+)
+
+func getKeySetCache() *keySetCache {
+	return _keySetCache
+}
+
+// lookup returns the key with the given kid for the issuer.
+func (c *keySetCache) lookup(ctx context.Context, issuer string, kid string) (JWK, error) {
+	c.m.RLock()
+	loaded := c.issuer == issuer && c.keys != nil
+	jwk, found := c.keys[kid]
+	expired := time.Now().After(c.keysExpires)
+	c.m.RUnlock()
+
+	if loaded && found && !expired {
+		return jwk, nil
+	}
+
+	if !c.canRefetch() {
+		if !loaded {
+			// the IdP failed lately, do not let every request wait on it again
+			slog.DebugContext(ctx, "Key set is not loaded, refetch is rate limited", "issuer", issuer)
+			return JWK{}, errKeySetRateLimited
+		}
+
+		if found {
+			// stale key is better than no key, background refresh will catch up
+			slog.DebugContext(ctx, "Using stale key, refetch is rate limited", "kid", kid)
+			return jwk, nil
+		}
+
+		slog.DebugContext(ctx, "Key not found for kid, refetch is rate limited", "kid", kid)
+		return JWK{}, fmt.Errorf("%w: %s", errUnknownKid, kid)
+	}
+
+	if !loaded {
+		slog.DebugContext(ctx, "Loading key set", "issuer", issuer)
+	} else if !found {
+		slog.DebugContext(ctx, "Unknown kid, refetching key set", "kid", kid)
+	}
+
+	err := c.refresh(ctx, issuer)
+
+	c.m.RLock()
+	jwk, found = c.keys[kid]
+	c.m.RUnlock()
+
+	if found {
+		if err != nil {
+			slog.WarnContext(ctx, "Key set refresh failed, using cached key", "kid", kid, "error", err)
+		}
+
+		return jwk, nil
+	}
+
+	if err != nil {
+		return JWK{}, err
+	}
+
+	slog.DebugContext(ctx, "Key not found for kid", "kid", kid)
+	return JWK{}, fmt.Errorf("%w: %s", errUnknownKid, kid)
+}
+
+// check reports whether the key set of the issuer is usable: it is loaded and
+// not older than keySetMaxStale. A set which is not loaded yet is loaded, a
+// loaded set is not refetched, the background refresh keeps it current and
+// retries failures. Cached keys still validate tokens while the IdP is
+// briefly down, so failed refreshes alone do not make every replica unready.
+func (c *keySetCache) check(ctx context.Context, issuer string) error {
+	c.m.RLock()
+	loaded := c.issuer == issuer && c.keys != nil
+	keysLoaded := c.keysLoaded
+	lastErr := c.lastErr
+	c.m.RUnlock()
+
+	if !loaded {
+		if !c.canRefetch() {
+			return errKeySetRateLimited
+		}
+
+		return c.refresh(ctx, issuer)
+	}
+
+	if lastErr != nil && time.Since(keysLoaded) > keySetMaxStale {
+		return fmt.Errorf("key set is stale, last refresh failed: %w", lastErr)
+	}
+
+	return nil
+}
+
+// canRefetch reports whether enough time passed since the last fetch.
+func (c *keySetCache) canRefetch() bool {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return time.Since(c.lastFetch) >= keySetKidMissInterval
+}
+
+// refresh fetches the discovery document when it is expired and the JWKS,
+// then schedules the next background refresh. The fetch is detached from the
+// caller's cancellation, a client going away must not count as a failed fetch
+// for everyone else.
+func (c *keySetCache) refresh(ctx context.Context, issuer string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), keySetFetchTimeout)
+	defer cancel()
+
+	c.fetchM.Lock()
+	defer c.fetchM.Unlock()
+
+	c.m.RLock()
+	oidcConfig := c.oidcConfig
+	oidcExpires := c.oidcExpires
+	lastFetch := c.lastFetch
+	sameIssuer := c.issuer == issuer
+	loaded := c.keys != nil
+	c.m.RUnlock()
+
+	if !sameIssuer {
+		oidcConfig = nil
+	} else if time.Since(lastFetch) < keySetKidMissInterval {
+		// another request fetched the set while we were waiting
+		if loaded {
+			return nil
+		}
+
+		return errKeySetRateLimited
+	}
+
+	if oidcConfig == nil || time.Now().After(oidcExpires) {
+		wellKnownURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+
+		newConfig, ttl, err := fetchOIDCConfig(ctx, wellKnownURL)
+
+		if err != nil {
+			slog.DebugContext(ctx, "Failed to fetch OIDC configuration", "error", err)
+			return c.markFailed(issuer, fmt.Errorf("failed to fetch OIDC configuration: %w", err))
+		}
+
+		if newConfig.JwksURI == "" {
+			return c.markFailed(issuer, errors.New("OIDC configuration has no jwks_uri"))
+		}
+
+		oidcConfig = newConfig
+		oidcExpires = time.Now().Add(ttl)
+	}
+
+	jwks, ttl, err := fetchJWKS(ctx, oidcConfig.JwksURI)
+
+	if err != nil {
+		slog.DebugContext(ctx, "Failed to fetch JWKS", "error", err)
+		return c.markFailed(issuer, fmt.Errorf("failed to fetch JWKS: %w", err))
+	}
+
+	keys := make(map[string]JWK, len(jwks.Keys))
+
+	for _, jwk := range jwks.Keys {
+		if jwk.Kid != "" {
+			keys[jwk.Kid] = jwk
+		}
+	}
+
+	now := time.Now()
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.issuer = issuer
+	c.oidcConfig = oidcConfig
+	c.oidcExpires = oidcExpires
+	c.keys = keys
+	c.keysExpires = now.Add(ttl)
+	c.keysLoaded = now
+	c.lastFetch = now
+	c.lastErr = nil
+
+	// refresh a bit before expiry so requests never wait on the IdP
+	c.scheduleRefresh(issuer, ttl-ttl/10)
+
+	slog.DebugContext(ctx, "Key set refreshed", "issuer", issuer, "keys", len(keys), "ttl", ttl)
+
+	return nil
+}
+
+// scheduleRefresh arms the background refresh timer, the caller must hold c.m.
+// Failed background refreshes are retried after the minimum TTL.
+func (c *keySetCache) scheduleRefresh(issuer string, after time.Duration) {
+	if c.refreshTimer != nil {
+		c.refreshTimer.Stop()
+	}
+
+	c.refreshTimer = time.AfterFunc(after, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), keySetFetchTimeout)
+		defer cancel()
+
+		if err := c.refresh(ctx, issuer); err != nil {
+			slog.WarnContext(ctx, "Background key set refresh failed", "issuer", issuer, "error", err)
+
+			c.m.Lock()
+			c.scheduleRefresh(issuer, keySetMinTTL)
+			c.m.Unlock()
+		}
+	})
+}
+
+// markFailed records a failed fetch attempt so kid misses stay rate limited
+// while the IdP is unavailable, and returns err.
+func (c *keySetCache) markFailed(issuer string, err error) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.issuer != issuer {
+		c.issuer = issuer
+		c.oidcConfig = nil
+		c.keys = nil
+	}
+
+	c.lastFetch = time.Now()
+	c.lastErr = err
+
+	return err
+}
+
+// cacheTTL computes how long a response may be cached using the
+// Cache-Control and Expires headers, clamped to sane bounds.
+func cacheTTL(resp *http.Response) time.Duration {
+	ttl := keySetDefaultTTL
+
+	if cc := resp.Header.Get("Cache-Control"); cc != "" {
+		for _, directive := range strings.Split(cc, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+
+			if directive == "no-cache" || directive == "no-store" {
+				return keySetMinTTL
+			}
+
+			if v, ok := strings.CutPrefix(directive, "max-age="); ok {
+				if secs, err := strconv.Atoi(strings.Trim(v, `"`)); err == nil {
+					return clampTTL(time.Duration(secs) * time.Second)
+				}
+			}
+		}
+	}
+
+	if exp := resp.Header.Get("Expires"); exp != "" {
+		expires, err := http.ParseTime(exp)
+
+		if err != nil {
+			// invalid Expires means already expired
+			return keySetMinTTL
+		}
+
+		now := time.Now()
+
+		if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			now = date
+		}
+
+		ttl = expires.Sub(now)
+	}
+
+	return clampTTL(ttl)
+}
+
+func clampTTL(ttl time.Duration) time.Duration {
+	if ttl < keySetMinTTL {
+		return keySetMinTTL
+	}
+
+	if ttl > keySetMaxTTL {
+		return keySetMaxTTL
+	}
+
+	return ttl
+}
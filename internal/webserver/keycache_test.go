@@ -0,0 +1,167 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"no headers", http.Header{}, keySetDefaultTTL},
+		{"max-age", http.Header{"Cache-Control": {"public, max-age=600"}}, 10 * time.Minute},
+		{"quoted max-age", http.Header{"Cache-Control": {`max-age="600"`}}, 10 * time.Minute},
+		{"max-age below minimum", http.Header{"Cache-Control": {"max-age=5"}}, keySetMinTTL},
+		{"max-age above maximum", http.Header{"Cache-Control": {"max-age=604800"}}, keySetMaxTTL},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache"}}, keySetMinTTL},
+		{"no-store wins over max-age", http.Header{"Cache-Control": {"no-store, max-age=600"}}, keySetMinTTL},
+		{"max-age wins over expires", http.Header{
+			"Cache-Control": {"max-age=600"},
+			"Expires":       {now.Add(2 * time.Hour).Format(http.TimeFormat)},
+		}, 10 * time.Minute},
+		{"expires relative to date", http.Header{
+			"Date":    {now.Add(-time.Hour).Format(http.TimeFormat)},
+			"Expires": {now.Format(http.TimeFormat)},
+		}, time.Hour},
+		{"invalid expires", http.Header{"Expires": {"0"}}, keySetMinTTL},
+		{"past expires", http.Header{"Expires": {now.Add(-time.Hour).Format(http.TimeFormat)}}, keySetMinTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheTTL(&http.Response{Header: tt.header}); got != tt.want {
+				t.Errorf("cacheTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeySetCacheRateLimitsUnavailableIdP(t *testing.T) {
+	var hits atomic.Int32
+
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer idp.Close()
+
+	c := &keySetCache{}
+
+	if _, err := c.lookup(context.Background(), idp.URL, "kid"); err == nil {
+		t.Fatal("lookup succeeded with the IdP down")
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := c.lookup(context.Background(), idp.URL, "kid"); !errors.Is(err, errKeySetRateLimited) {
+			t.Fatalf("lookup error = %v, want %v", err, errKeySetRateLimited)
+		}
+	}
+
+	if err := c.check(context.Background(), idp.URL); !errors.Is(err, errKeySetRateLimited) {
+		t.Fatalf("check error = %v, want %v", err, errKeySetRateLimited)
+	}
+
+	if n := hits.Load(); n != 1 {
+		t.Errorf("IdP was called %d times, want 1", n)
+	}
+}
+
+func TestKeySetCacheIgnoresCallerCancellation(t *testing.T) {
+	idp := newTestIdP(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := &keySetCache{}
+	defer c.stopRefresh()
+
+	if _, err := c.lookup(ctx, idp.URL, testKid); err != nil {
+		t.Fatalf("lookup with a cancelled context: %v", err)
+	}
+}
+
+func TestKeySetCacheUnknownKid(t *testing.T) {
+	idp := newTestIdP(t)
+
+	c := &keySetCache{}
+	defer c.stopRefresh()
+
+	if _, err := c.lookup(context.Background(), idp.URL, testKid); err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+
+	fetches := idp.jwksHits.Load()
+
+	// a kid miss right after a fetch is rate limited, the IdP is not called
+	if _, err := c.lookup(context.Background(), idp.URL, "other"); !errors.Is(err, errUnknownKid) {
+		t.Fatalf("lookup error = %v, want %v", err, errUnknownKid)
+	}
+
+	if n := idp.jwksHits.Load(); n != fetches {
+		t.Errorf("JWKS was fetched %d times, want %d", n, fetches)
+	}
+}
+
+// stopRefresh stops the background refresh of a test cache.
+func (c *keySetCache) stopRefresh() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.refreshTimer != nil {
+		c.refreshTimer.Stop()
+	}
+}
+
+func TestKeySetCacheCheckToleratesFailedRefresh(t *testing.T) {
+	idp := newTestIdP(t)
+
+	c := &keySetCache{}
+	defer c.stopRefresh()
+
+	if err := c.check(context.Background(), idp.URL); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+
+	idp.Close()
+
+	// a refresh after the IdP went away, like the background refresh does
+	c.m.Lock()
+	c.lastFetch = time.Time{}
+	c.oidcExpires = time.Time{}
+	c.m.Unlock()
+
+	if err := c.refresh(context.Background(), idp.URL); err == nil {
+		t.Fatal("refresh succeeded with the IdP down")
+	}
+
+	// the cached keys are still served and keep the instance ready
+	if err := c.check(context.Background(), idp.URL); err != nil {
+		t.Errorf("check after a failed refresh: %v", err)
+	}
+
+	if _, err := c.lookup(context.Background(), idp.URL, testKid); err != nil {
+		t.Errorf("lookup after a failed refresh: %v", err)
+	}
+
+	c.m.Lock()
+	c.keysLoaded = time.Now().Add(-keySetMaxStale - time.Minute)
+	c.m.Unlock()
+
+	if err := c.check(context.Background(), idp.URL); err == nil {
+		t.Error("check succeeded with stale keys")
+	}
+}
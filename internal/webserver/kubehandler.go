@@ -0,0 +1,235 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/kube"
+)
+
+func init() {
+	RegisterAction("get_cluster_version", getClusterVersion, WithAuth(),
+		WithDescription("Returns the version of the Kubernetes API server"))
+	RegisterAction("list_namespaces", listNamespaces, WithAuth(),
+		WithDescription("Lists the namespaces of the cluster"))
+	RegisterAction("list_pods", listPods, WithAuth(),
+		WithDescription("Lists the pods of a namespace, or of all namespaces when namespace is empty"))
+}
+
+type ClusterVersionRequest struct{}
+
+type ClusterVersionResponse struct {
+	GitVersion string `json:"git_version"`
+	Platform   string `json:"platform"`
+}
+
+type ListNamespacesRequest struct {
+	LabelSelector string `json:"label_selector,omitempty" description:"Kubernetes label selector"`
+	Limit         int    `json:"limit,omitempty" description:"Maximum number of items, use continue for the next page"`
+	Continue      string `json:"continue,omitempty" description:"Continue token of the previous page"`
+}
+
+type NamespaceItem struct {
+	Name      string            `json:"name"`
+	Phase     string            `json:"phase"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type ListNamespacesResponse struct {
+	Items    []NamespaceItem `json:"items"`
+	Continue string          `json:"continue,omitempty"`
+}
+
+type ListPodsRequest struct {
+	Namespace     string `json:"namespace,omitempty" description:"Namespace of the pods, all namespaces when empty"`
+	LabelSelector string `json:"label_selector,omitempty" description:"Kubernetes label selector"`
+	FieldSelector string `json:"field_selector,omitempty" description:"Kubernetes field selector"`
+	Limit         int    `json:"limit,omitempty" description:"Maximum number of items, use continue for the next page"`
+	Continue      string `json:"continue,omitempty" description:"Continue token of the previous page"`
+}
+
+type PodItem struct {
+	Name       string    `json:"name"`
+	Namespace  string    `json:"namespace"`
+	Phase      string    `json:"phase"`
+	Node       string    `json:"node,omitempty"`
+	Ready      int       `json:"ready"`
+	Containers int       `json:"containers"`
+	Restarts   int       `json:"restarts"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ListPodsResponse struct {
+	Items    []PodItem `json:"items"`
+	Continue string    `json:"continue,omitempty"`
+}
+
+// kubeClient returns the Kubernetes client with a context whose requests
+// are made as the caller, so RBAC of the cluster decides what they see.
+func kubeClient(ctx context.Context) (*kube.Client, context.Context, error) {
+	c := kube.GetClient()
+
+	if c == nil {
+		return nil, ctx, NewAPIError(ErrUnavailable, "Kubernetes is not configured")
+	}
+
+	if p, ok := PrincipalFromContext(ctx); ok {
+		ctx = kube.WithUser(ctx, &kube.User{
+			Name:   p.Username,
+			Groups: p.Groups,
+			Token:  p.Token,
+		})
+	}
+
+	return c, ctx, nil
+}
+
+// kubeAPIError maps Kubernetes API failures to api errors. Messages of the
+// API server are passed as they name the missing permission or object. A
+// rejected server credential is not the caller's fault, so it is reported as
+// unavailable like connection failures.
+func kubeAPIError(ctx context.Context, err error) error {
+	if errors.Is(err, kube.ErrReservedUser) {
+		return NewAPIError(ErrForbidden, "User can not access Kubernetes")
+	}
+
+	if errors.Is(err, kube.ErrInvalidNamespace) {
+		return NewAPIError(ErrInvalidParameters, err.Error())
+	}
+
+	if errors.Is(err, kube.ErrNoUser) {
+		return NewAPIError(ErrUnauthorized, "Kubernetes access requires authentication")
+	}
+
+	var statusErr *kube.StatusError
+
+	if errors.As(err, &statusErr) {
+		switch statusErr.Code {
+		case http.StatusUnauthorized:
+			// only the caller's own token can be rejected for the caller
+			if kube.GetClient().AuthMode() == kube.AuthModeToken {
+				return NewAPIError(ErrUnauthorized, statusErr.Message)
+			}
+		case http.StatusForbidden:
+			return NewAPIError(ErrForbidden, statusErr.Message)
+		case http.StatusNotFound:
+			return NewAPIError(ErrNotFound, statusErr.Message)
+		case http.StatusBadRequest, http.StatusUnprocessableEntity:
+			return NewAPIError(ErrInvalidParameters, statusErr.Message)
+		case http.StatusConflict:
+			return NewAPIError(ErrConflict, statusErr.Message)
+		case http.StatusTooManyRequests:
+			return NewAPIError(ErrRateLimited, statusErr.Message)
+		}
+	}
+
+	slog.ErrorContext(ctx, "Kubernetes request failed", "error", err)
+
+	return NewAPIError(ErrUnavailable, "Kubernetes API server is not available")
+}
+
+func getClusterVersion(ctx context.Context, req ClusterVersionRequest) (ClusterVersionResponse, error) {
+	c, ctx, err := kubeClient(ctx)
+
+	if err != nil {
+		return ClusterVersionResponse{}, err
+	}
+
+	// asked as the caller, unlike the startup check which uses the service account
+	var v kube.VersionInfo
+
+	if err := c.Get(ctx, "/version", nil, &v); err != nil {
+		return ClusterVersionResponse{}, kubeAPIError(ctx, err)
+	}
+
+	return ClusterVersionResponse{GitVersion: v.GitVersion, Platform: v.Platform}, nil
+}
+
+func listNamespaces(ctx context.Context, req ListNamespacesRequest) (ListNamespacesResponse, error) {
+	c, ctx, err := kubeClient(ctx)
+
+	if err != nil {
+		return ListNamespacesResponse{}, err
+	}
+
+	list, err := c.ListNamespaces(ctx, kube.ListOptions{
+		LabelSelector: req.LabelSelector,
+		Limit:         req.Limit,
+		Continue:      req.Continue,
+	})
+
+	if err != nil {
+		return ListNamespacesResponse{}, kubeAPIError(ctx, err)
+	}
+
+	resp := ListNamespacesResponse{
+		Items:    make([]NamespaceItem, 0, len(list.Items)),
+		Continue: list.Metadata.Continue,
+	}
+
+	for _, ns := range list.Items {
+		resp.Items = append(resp.Items, NamespaceItem{
+			Name:      ns.Metadata.Name,
+			Phase:     ns.Status.Phase,
+			Labels:    ns.Metadata.Labels,
+			CreatedAt: ns.Metadata.CreationTimestamp,
+		})
+	}
+
+	return resp, nil
+}
+
+func listPods(ctx context.Context, req ListPodsRequest) (ListPodsResponse, error) {
+	c, ctx, err := kubeClient(ctx)
+
+	if err != nil {
+		return ListPodsResponse{}, err
+	}
+
+	list, err := c.ListPods(ctx, req.Namespace, kube.ListOptions{
+		LabelSelector: req.LabelSelector,
+		FieldSelector: req.FieldSelector,
+		Limit:         req.Limit,
+		Continue:      req.Continue,
+	})
+
+	if err != nil {
+		return ListPodsResponse{}, kubeAPIError(ctx, err)
+	}
+
+	resp := ListPodsResponse{
+		Items:    make([]PodItem, 0, len(list.Items)),
+		Continue: list.Metadata.Continue,
+	}
+
+	for _, pod := range list.Items {
+		item := PodItem{
+			Name:       pod.Metadata.Name,
+			Namespace:  pod.Metadata.Namespace,
+			Phase:      pod.Status.Phase,
+			Node:       pod.Spec.NodeName,
+			Containers: len(pod.Spec.Containers),
+			CreatedAt:  pod.Metadata.CreationTimestamp,
+		}
+
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Ready {
+				item.Ready++
+			}
+
+			item.Restarts += cs.RestartCount
+		}
+
+		resp.Items = append(resp.Items, item)
+	}
+
+	return resp, nil
+}
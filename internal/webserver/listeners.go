@@ -0,0 +1,218 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/config"
+)
+
+// systemd passes activated sockets starting from this file descriptor.
+const systemdListenFDsStart = 3
+
+// openListeners opens the configured listen addresses. Sockets passed by
+// systemd socket activation are used too; when there are any and no address
+// is configured, only they are used. Without both :serverPort is used.
+func openListeners() ([]net.Listener, error) {
+	listeners, err := systemdListeners()
+
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := config.GetConfig().GetListen()
+
+	if len(addresses) == 0 && len(listeners) == 0 {
+		addresses = []string{fmt.Sprintf(":%d", config.GetConfig().GetServerPort())}
+	}
+
+	for _, address := range addresses {
+		l, err := listen(address)
+
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+
+		slog.Info("Listening", "address", address)
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		if err := l.Close(); err != nil {
+			slog.Error("Error closing listener", "address", l.Addr(), "error", err)
+		}
+	}
+}
+
+// listen opens a TCP listener for host:port or a Unix socket for
+// unix:///path with an optional mode query parameter for permissions.
+func listen(address string) (net.Listener, error) {
+	if !strings.HasPrefix(address, "unix://") {
+		l, err := net.Listen("tcp", address)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+		}
+
+		return l, nil
+	}
+
+	u, err := url.Parse(address)
+
+	if err != nil || u.Path == "" || u.Host != "" {
+		return nil, fmt.Errorf("invalid unix socket address: %s", address)
+	}
+
+	mode := fs.FileMode(0o660)
+
+	if m := u.Query().Get("mode"); m != "" {
+		v, err := strconv.ParseUint(m, 8, 32)
+
+		if err != nil || v > 0o777 {
+			return nil, fmt.Errorf("invalid unix socket mode: %s", m)
+		}
+
+		mode = fs.FileMode(v)
+	}
+
+	// remove a stale socket left by an unclean exit, never a regular file
+	if fi, err := os.Lstat(u.Path); err == nil {
+		if fi.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("unix socket path exists and is not a socket: %s", u.Path)
+		}
+
+		if err := os.Remove(u.Path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale unix socket: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to check unix socket path: %w", err)
+	}
+
+	l, err := listenUnix(u.Path, mode)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	return l, nil
+}
+
+// unixListener removes its socket file on close. The socket is bound under a
+// temporary name, so the listener cannot remove it by itself.
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+
+	if rmErr := os.Remove(l.path); rmErr != nil && !errors.Is(rmErr, fs.ErrNotExist) && err == nil {
+		err = rmErr
+	}
+
+	return err
+}
+
+// listenUnix binds the socket in a private directory next to path, sets its
+// mode and moves it into place, so it is never reachable with the umask's
+// permissions.
+func listenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".sock-")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "s")
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmpPath, Net: "unix"})
+
+	if err != nil {
+		return nil, err
+	}
+
+	l.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to set unix socket mode: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to move unix socket into place: %w", err)
+	}
+
+	return &unixListener{UnixListener: l, path: path}, nil
+}
+
+// systemdListeners returns sockets passed with systemd socket activation.
+func systemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// do not pass the sockets to child processes
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, count)
+
+	for i := 0; i < count; i++ {
+		fd := systemdListenFDsStart + i
+
+		name := fmt.Sprintf("LISTEN_FD_%d", fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+
+		l, err := net.FileListener(f)
+		// FileListener dups the descriptor
+		f.Close()
+
+		if err != nil {
+			closeListeners(listeners)
+			return nil, fmt.Errorf("failed to use systemd socket %s: %w", name, err)
+		}
+
+		slog.Info("Listening on systemd socket", "name", name, "address", l.Addr())
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
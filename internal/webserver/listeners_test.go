@@ -0,0 +1,112 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  fs.FileMode
+	}{
+		{"default mode", "", 0o660},
+		{"private", "?mode=0600", 0o600},
+		{"world", "?mode=0666", 0o666},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.sock")
+
+			l, err := listen("unix://" + path + tt.query)
+
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+
+			fi, err := os.Lstat(path)
+
+			if err != nil {
+				t.Fatalf("stat socket: %v", err)
+			}
+
+			if fi.Mode()&fs.ModeSocket == 0 || fi.Mode().Perm() != tt.want {
+				t.Errorf("socket mode = %v, want socket with %v", fi.Mode(), tt.want)
+			}
+
+			conn, err := net.Dial("unix", path)
+
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+
+			conn.Close()
+
+			if err := l.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+
+			// neither the socket nor the private directory is left behind
+			entries, _ := os.ReadDir(dir)
+
+			if len(entries) != 0 {
+				t.Errorf("directory not empty after close: %v", entries)
+			}
+		})
+	}
+}
+
+func TestListenUnixExistingPath(t *testing.T) {
+	dir := t.TempDir()
+
+	stale := filepath.Join(dir, "stale.sock")
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: stale, Net: "unix"})
+
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	// leave the socket file behind like an unclean exit
+	l.SetUnlinkOnClose(false)
+	l.Close()
+
+	l2, err := listen("unix://" + stale)
+
+	if err != nil {
+		t.Fatalf("listen on stale socket: %v", err)
+	}
+
+	l2.Close()
+
+	regular := filepath.Join(dir, "file")
+
+	if err := os.WriteFile(regular, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := listen("unix://" + regular); err == nil {
+		t.Error("listen replaced a regular file")
+	}
+
+	if _, err := os.Stat(regular); errors.Is(err, fs.ErrNotExist) {
+		t.Error("regular file was removed")
+	}
+
+	for _, address := range []string{"unix://", "unix://host/path.sock", "unix://" + filepath.Join(dir, "s.sock") + "?mode=999"} {
+		if _, err := listen(address); err == nil {
+			t.Errorf("listen(%q) succeeded", address)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/kazimsarikaya/go_react_mui/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	httpRequestsTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by route and status.",
+	}, []string{"route", "method", "status"})
+	httpRequestDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	apiActionCallsTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_action_calls_total",
+		Help: "Number of api action calls.",
+	}, []string{"action"})
+	apiActionErrorsTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_action_errors_total",
+		Help: "Number of api action calls answered with an error status.",
+	}, []string{"action", "status"})
+	apiActionDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "api_action_duration_seconds",
+		Help:    "Api action latency including authentication.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"action"})
+
+	tokenValidationsTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "token_validations_total",
+		Help: "Number of bearer token validations by outcome.",
+	}, []string{"outcome"})
+
+	jwksFetchDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "jwks_fetch_duration_seconds",
+		Help:    "Latency of fetching the JWKS of the OIDC issuer.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	watchEventsTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "watch_events_total",
+		Help: "Number of Kubernetes watch events streamed to clients.",
+	}, []string{"resource", "type"})
+	watchStreamsActive = metrics.Factory.NewGauge(prometheus.GaugeOpts{
+		Name: "watch_streams_active",
+		Help: "Number of open Kubernetes watch streams.",
+	})
+)
+
+func handleMetrics(r *mux.Router) {
+	r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+}
+
+// statusRecorder remembers the status code written to the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.status == 0 {
+		sr.status = code
+	}
+
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+
+	return sr.ResponseWriter.Write(b)
+}
+
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+func (sr *statusRecorder) statusCode() int {
+	if sr.status == 0 {
+		return http.StatusOK
+	}
+
+	return sr.status
+}
+
+// metricsMiddleware counts requests and their latency by the route template,
+// which keeps the label cardinality bounded unlike raw paths. Watch streams
+// last for minutes and would skew the latency histogram, they are counted by
+// watch_streams_active instead.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cr := mux.CurrentRoute(r); cr != nil && cr.GetName() == watchRouteName {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(sr, r)
+
+		route := "unmatched"
+
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tpl, err := cr.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		status := strconv.Itoa(sr.statusCode())
+
+		httpRequestsTotal.WithLabelValues(route, r.Method, status).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// observeAction records a call of a registered action.
+func observeAction(action string, status int, elapsed time.Duration) {
+	apiActionCallsTotal.WithLabelValues(action).Inc()
+	apiActionDuration.WithLabelValues(action).Observe(elapsed.Seconds())
+
+	if status >= http.StatusBadRequest {
+		apiActionErrorsTotal.WithLabelValues(action, strconv.Itoa(status)).Inc()
+	}
+}
+
+// tokenValidationOutcome classifies a validateToken error for metrics.
+func tokenValidationOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "valid"
+	case errors.Is(err, errTokenExpired), errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, errInvalidAudience):
+		return "bad_audience"
+	case errors.Is(err, errInvalidIssuer):
+		return "bad_issuer"
+	case errors.Is(err, errUnknownKid):
+		return "unknown_kid"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return "bad_signature"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "malformed"
+	}
+
+	return "invalid"
+}
@@ -0,0 +1,62 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddlewareLabels(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(metricsMiddleware)
+
+	r.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "new" {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		w.Write([]byte("item"))
+	}).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {}).Name(watchRouteName)
+	r.NotFoundHandler = metricsMiddleware(http.HandlerFunc(NotFoundHandler))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		route  string
+		status string
+		want   float64
+	}{
+		{name: "route template", method: http.MethodGet, path: "/items/42", route: "/items/{id}", status: "200", want: 1},
+		{name: "written status", method: http.MethodPost, path: "/items/new", route: "/items/{id}", status: "201", want: 1},
+		{name: "not found", method: http.MethodGet, path: "/nothing/here", route: "unmatched", status: "404", want: 1},
+		{name: "watch streams are skipped", method: http.MethodGet, path: "/stream", route: "/stream", status: "200", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := httpRequestsTotal.WithLabelValues(tt.route, tt.method, tt.status)
+			before := testutil.ToFloat64(counter)
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+			if got := testutil.ToFloat64(counter) - before; got != tt.want {
+				t.Errorf("http_requests_total{route=%q,method=%q,status=%q} grew by %v, want %v", tt.route, tt.method, tt.status, got, tt.want)
+			}
+		})
+	}
+
+	// raw paths never become labels
+	if n := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("/items/42", http.MethodGet, "200")); n != 0 {
+		t.Errorf("raw path is used as the route label %v times", n)
+	}
+}
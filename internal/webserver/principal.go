@@ -0,0 +1,43 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal is the authenticated caller of an api action.
+type Principal struct {
+	Username string
+	Groups   []string
+	Claims   map[string]interface{}
+	// Token is the validated bearer token, for calls made as the caller.
+	Token string
+}
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+// PrincipalFromContext returns the principal of the request, if authenticated.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// InGroup reports whether the principal is member of the group or role.
+func (p *Principal) InGroup(group string) bool {
+	return slices.Contains(p.Groups, group)
+}
+
+// Claim returns a raw token claim.
+func (p *Principal) Claim(name string) (interface{}, bool) {
+	v, ok := p.Claims[name]
+	return v, ok
+}
@@ -0,0 +1,273 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// JSON-RPC 2.0 error codes, server defined codes are in -32000 to -32099.
+// The full api error is sent as error data.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcServerError    = -32000
+	rpcUnauthorized   = -32001
+	rpcForbidden      = -32003
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  json.RawMessage `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+var rpcNullID = json.RawMessage("null")
+
+// RpcHandler serves the api actions with JSON-RPC 2.0 semantics. Methods are
+// the action names and by-name params are the action parameters. Requests
+// without id are notifications and get no response. Batch items run
+// concurrently when the parallel query parameter is set.
+func RpcHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, r, "method is not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if requestMediaType(r) != "application/json" {
+		sendAPIError(w, r, NewAPIError(ErrUnsupportedMediaType, "Content-Type is not allowed"))
+		return
+	}
+
+	parallel, err := parallelParam(r)
+
+	if err != nil {
+		sendError(w, r, "parallel parameter is invalid", http.StatusBadRequest)
+		return
+	}
+
+	limits := apiRequestLimits()
+
+	buf, err := readLimitedBody(w, r, limits)
+
+	if err != nil {
+		sendAPIError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	buf = bytes.TrimSpace(buf)
+
+	// the decoded value is not used, decoding enforces the JSON limits
+	if _, err := decodeStrictJSON(buf, limits); err != nil {
+		apiErr := jsonDecodeAPIError(err, "request body")
+
+		if apiErr.Code == ErrPayloadTooLarge {
+			sendAPIError(w, r, apiErr)
+			return
+		}
+
+		writeRpcResponse(w, r, newRpcErrorResponse(rpcNullID, rpcParseError, "Parse error", apiErr))
+		return
+	}
+
+	if buf[0] != '[' {
+		if resp := runRpcRequest(r, buf); resp != nil {
+			writeRpcResponse(w, r, resp)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		return
+	}
+
+	var items []json.RawMessage
+
+	if err := json.Unmarshal(buf, &items); err != nil || len(items) == 0 {
+		writeRpcResponse(w, r, newRpcErrorResponse(rpcNullID, rpcInvalidRequest, "Invalid Request", nil))
+		return
+	}
+
+	if len(items) > maxBatchSize {
+		writeRpcResponse(w, r, newRpcErrorResponse(rpcNullID, rpcInvalidRequest, "Invalid Request", "batch is too large"))
+		return
+	}
+
+	responses := make([]*rpcResponse, len(items))
+
+	forEachItem(len(items), parallel, func(i int) {
+		responses[i] = runRpcRequest(r, items[i])
+	})
+
+	// notifications have no entry in the batch response
+	batch := make([]*rpcResponse, 0, len(responses))
+
+	for _, resp := range responses {
+		if resp != nil {
+			batch = append(batch, resp)
+		}
+	}
+
+	if len(batch) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeRpcResponse(w, r, batch)
+}
+
+// runRpcRequest runs a single request and returns its response, or nil for
+// notifications.
+func runRpcRequest(r *http.Request, raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return newRpcErrorResponse(rpcNullID, rpcInvalidRequest, "Invalid Request", nil)
+	}
+
+	id := req.ID
+	notification := id == nil
+
+	if notification {
+		id = rpcNullID
+	} else if !validRpcID(id) {
+		return newRpcErrorResponse(rpcNullID, rpcInvalidRequest, "Invalid Request", "id must be a string, number or null")
+	}
+
+	var method string
+
+	if req.JSONRPC != "2.0" || json.Unmarshal(req.Method, &method) != nil || method == "" {
+		return newRpcErrorResponse(id, rpcInvalidRequest, "Invalid Request", nil)
+	}
+
+	if _, ok := apiActions[method]; !ok {
+		return rpcResult(notification, newRpcErrorResponse(id, rpcMethodNotFound, "Method not found", nil))
+	}
+
+	params := map[string]interface{}{}
+	trimmed := bytes.TrimSpace(req.Params)
+
+	switch {
+	case len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")):
+	case trimmed[0] == '{':
+		if err := json.Unmarshal(trimmed, &params); err != nil {
+			return rpcResult(notification, newRpcErrorResponse(id, rpcInvalidParams, "Invalid params", nil))
+		}
+	case bytes.Equal(trimmed, []byte("[]")):
+	default:
+		return rpcResult(notification, newRpcErrorResponse(id, rpcInvalidParams, "Invalid params", "positional params are not supported"))
+	}
+
+	params["action"] = method
+
+	status, result, errValue := callActionBuffered(r, params)
+
+	if status >= http.StatusBadRequest {
+		var apiErr APIError
+
+		if err := json.Unmarshal(errValue, &apiErr); err != nil || apiErr.Message == "" {
+			apiErr = *NewAPIError(statusErrorCode(status), http.StatusText(status))
+		}
+
+		slog.DebugContext(r.Context(), "JSON-RPC call failed", "method", method, "status", status, "code", apiErr.Code)
+
+		return rpcResult(notification, newRpcErrorResponse(id, rpcErrorCode(apiErr.Code, status), apiErr.Message, &apiErr))
+	}
+
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+
+	return rpcResult(notification, &rpcResponse{JSONRPC: "2.0", Result: result, ID: id})
+}
+
+func rpcResult(notification bool, resp *rpcResponse) *rpcResponse {
+	if notification {
+		return nil
+	}
+
+	return resp
+}
+
+func newRpcErrorResponse(id json.RawMessage, code int, message string, data interface{}) *rpcResponse {
+	return &rpcResponse{
+		JSONRPC: "2.0",
+		Error:   &rpcError{Code: code, Message: message, Data: data},
+		ID:      id,
+	}
+}
+
+func validRpcID(id json.RawMessage) bool {
+	var v interface{}
+
+	if err := json.Unmarshal(id, &v); err != nil {
+		return false
+	}
+
+	switch v.(type) {
+	case nil, string, float64:
+		return true
+	}
+
+	return false
+}
+
+// rpcErrorCode maps an api error code to a JSON-RPC error code.
+func rpcErrorCode(code ErrorCode, status int) int {
+	switch code {
+	case ErrInvalidParameters, ErrBadRequest:
+		return rpcInvalidParams
+	case ErrUnknownAction:
+		return rpcMethodNotFound
+	case ErrUnauthorized:
+		return rpcUnauthorized
+	case ErrForbidden:
+		return rpcForbidden
+	}
+
+	if status >= http.StatusInternalServerError {
+		return rpcInternalError
+	}
+
+	return rpcServerError
+}
+
+func writeRpcResponse(w http.ResponseWriter, r *http.Request, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	buf, err := json.Marshal(v)
+
+	if err != nil {
+		sendAPIError(w, r, err)
+		return
+	}
+
+	_, err = w.Write(buf)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error writing response", "error", err)
+	}
+}
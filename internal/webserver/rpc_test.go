@@ -0,0 +1,198 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testEchoRequest struct {
+	Value string `json:"value"`
+}
+
+type testEchoResponse struct {
+	Value string `json:"value"`
+}
+
+func init() {
+	RegisterAction("test_echo", func(ctx context.Context, req testEchoRequest) (testEchoResponse, error) {
+		return testEchoResponse{Value: req.Value}, nil
+	})
+	RegisterAction("test_conflict", func(ctx context.Context, req struct{}) (struct{}, error) {
+		return struct{}{}, NewAPIError(ErrConflict, "already exists")
+	})
+	RegisterAction("test_internal", func(ctx context.Context, req struct{}) (struct{}, error) {
+		return struct{}{}, errors.New("database is gone")
+	})
+	RegisterAction("test_auth", func(ctx context.Context, req struct{}) (struct{}, error) {
+		return struct{}{}, nil
+	}, WithAuth())
+}
+
+func serveRpc(t *testing.T, query string, contentType string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/rpc"+query, strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+
+	w := httptest.NewRecorder()
+	RpcHandler(w, r)
+
+	return w
+}
+
+func TestRpcHandler(t *testing.T) {
+	setTestConfig(t, nil)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantData ErrorCode
+		result   string
+	}{
+		{name: "result", body: `{"jsonrpc":"2.0","method":"test_echo","params":{"value":"hi"},"id":1}`, result: `{"value":"hi"}`},
+		{name: "empty positional params", body: `{"jsonrpc":"2.0","method":"test_echo","params":[],"id":1}`, result: `{"value":""}`},
+		{name: "parse error", body: `{"jsonrpc":"2.0",`, wantCode: rpcParseError},
+		{name: "duplicate key", body: `{"jsonrpc":"2.0","method":"test_echo","method":"x","id":1}`, wantCode: rpcParseError},
+		{name: "wrong version", body: `{"jsonrpc":"1.0","method":"test_echo","id":1}`, wantCode: rpcInvalidRequest},
+		{name: "method not a string", body: `{"jsonrpc":"2.0","method":1,"id":1}`, wantCode: rpcInvalidRequest},
+		{name: "object id", body: `{"jsonrpc":"2.0","method":"test_echo","id":{}}`, wantCode: rpcInvalidRequest},
+		{name: "scalar request", body: `1`, wantCode: rpcInvalidRequest},
+		{name: "empty batch", body: `[]`, wantCode: rpcInvalidRequest},
+		{name: "unknown method", body: `{"jsonrpc":"2.0","method":"nope","id":1}`, wantCode: rpcMethodNotFound},
+		{name: "positional params", body: `{"jsonrpc":"2.0","method":"test_echo","params":["hi"],"id":1}`, wantCode: rpcInvalidParams},
+		{name: "unknown param", body: `{"jsonrpc":"2.0","method":"test_echo","params":{"bogus":1},"id":1}`, wantCode: rpcInvalidParams, wantData: ErrInvalidParameters},
+		{name: "param of wrong type", body: `{"jsonrpc":"2.0","method":"test_echo","params":{"value":1},"id":1}`, wantCode: rpcInvalidParams, wantData: ErrInvalidParameters},
+		{name: "api error", body: `{"jsonrpc":"2.0","method":"test_conflict","id":1}`, wantCode: rpcServerError, wantData: ErrConflict},
+		{name: "internal error", body: `{"jsonrpc":"2.0","method":"test_internal","id":1}`, wantCode: rpcInternalError, wantData: ErrInternal},
+		{name: "unauthorized", body: `{"jsonrpc":"2.0","method":"test_auth","id":1}`, wantCode: rpcUnauthorized, wantData: ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRpc(t, "", "application/json", tt.body)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+
+			var resp struct {
+				JSONRPC string          `json:"jsonrpc"`
+				Result  json.RawMessage `json:"result"`
+				Error   *struct {
+					Code int             `json:"code"`
+					Data json.RawMessage `json:"data"`
+				} `json:"error"`
+			}
+
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response %s: %v", w.Body, err)
+			}
+
+			if resp.JSONRPC != "2.0" {
+				t.Errorf("jsonrpc = %q", resp.JSONRPC)
+			}
+
+			if tt.wantCode == 0 {
+				if resp.Error != nil || string(resp.Result) != tt.result {
+					t.Errorf("response = %s, want result %s", w.Body, tt.result)
+				}
+
+				return
+			}
+
+			if resp.Error == nil || resp.Error.Code != tt.wantCode {
+				t.Fatalf("response = %s, want error code %d", w.Body, tt.wantCode)
+			}
+
+			if tt.wantData == "" {
+				return
+			}
+
+			var data APIError
+
+			if err := json.Unmarshal(resp.Error.Data, &data); err != nil || data.Code != tt.wantData {
+				t.Errorf("error data = %s, want code %s", resp.Error.Data, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestRpcHandlerBatch(t *testing.T) {
+	setTestConfig(t, nil)
+
+	batch := `[
+		{"jsonrpc":"2.0","method":"test_echo","params":{"value":"a"},"id":"a"},
+		{"jsonrpc":"2.0","method":"test_echo","params":{"value":"n"}},
+		{"jsonrpc":"2.0","method":"nope","id":2}
+	]`
+
+	for _, query := range []string{"", "?parallel=true", "?parallel=1", "?parallel=false"} {
+		t.Run(query, func(t *testing.T) {
+			w := serveRpc(t, query, "application/json; charset=utf-8", batch)
+
+			var resp []struct {
+				ID    json.RawMessage `json:"id"`
+				Error *rpcError       `json:"error"`
+			}
+
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response %s: %v", w.Body, err)
+			}
+
+			// the notification has no response
+			if len(resp) != 2 || string(resp[0].ID) != `"a"` || resp[0].Error != nil ||
+				string(resp[1].ID) != "2" || resp[1].Error == nil || resp[1].Error.Code != rpcMethodNotFound {
+				t.Errorf("response = %s", w.Body)
+			}
+		})
+	}
+
+	t.Run("notifications only", func(t *testing.T) {
+		w := serveRpc(t, "", "application/json", `[{"jsonrpc":"2.0","method":"test_echo"}]`)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+		}
+	})
+
+	t.Run("invalid parallel", func(t *testing.T) {
+		w := serveRpc(t, "?parallel=yes", "application/json", batch)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestRpcHandlerTransportErrors(t *testing.T) {
+	setTestConfig(t, map[string]interface{}{"apiMaxBodySize": 64})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"form content type", "application/x-www-form-urlencoded", `{}`, http.StatusUnsupportedMediaType},
+		{"missing content type", "", `{}`, http.StatusUnsupportedMediaType},
+		{"body too large", "application/json", `{"jsonrpc":"2.0","method":"test_echo","params":{"value":"` + strings.Repeat("x", 64) + `"}}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serveRpc(t, "", tt.contentType, tt.body); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
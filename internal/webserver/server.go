@@ -6,11 +6,10 @@ package webserver
 
 import (
 	"compress/gzip"
-	"fmt"
+	"context"
+	"errors"
 	"log/slog"
-	"net"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/gorilla/handlers"
@@ -23,51 +22,93 @@ import (
 )
 
 func StartWebServer() (*http.Server, error) {
-	var listener net.Listener
-	var err error
+	listeners, err := openListeners()
 
-	listener, err = net.Listen("tcp", fmt.Sprintf(":%d", config.GetConfig().GetServerPort()))
+	if err != nil {
+		slog.Error("Error starting listeners", "error", err)
+		return nil, err
+	}
+
+	// TLS material is reloaded in background until the server shuts down
+	tlsCtx, tlsCancel := context.WithCancel(context.Background())
+
+	tlsConfig, err := buildTLSConfig(tlsCtx)
 
 	if err != nil {
-		slog.Error("Error starting TCP listener", "error", err)
+		tlsCancel()
+		closeListeners(listeners)
+		slog.Error("Error configuring TLS", "error", err)
 		return nil, err
 	}
 
 	if config.GetConfig().GetLocalStaticPath() != "" || config.GetConfig().GetDebug() {
 		// from folder frontend/dist
 		slog.Info("Serving static files from local path", "path", config.GetConfig().GetLocalStaticPath())
-		staticHandler = http.FileServer(http.Dir(config.GetConfig().GetLocalStaticPath()))
+		staticFS = http.Dir(config.GetConfig().GetLocalStaticPath())
 	} else {
 		slog.Info("Serving static files from embedded resources")
-		staticHandler = http.FileServer(http.FS(static.Static))
+		staticFS = http.FS(static.Static)
 	}
 
+	staticHandler = http.FileServer(staticFS)
+
+	registerHealthChecks()
+
 	// Create a router
 	r := mux.NewRouter()
 
+	// Kubernetes watch stream, outside of the api subrouter as compression
+	// would hold back events
+	r.HandleFunc("/api/watch", WatchHandler).Methods(http.MethodGet).Name(watchRouteName)
+
 	// Subrouter for /api paths
 	apiRouter := r.PathPrefix("/api").Subrouter()
 
 	apiRouter.HandleFunc("", ApiHandler).Methods(http.MethodGet, http.MethodPost)
+	apiRouter.HandleFunc("/rpc", RpcHandler).Methods(http.MethodPost)
 
 	apiRouter.Use(func(next http.Handler) http.Handler {
 		return handlers.CompressHandlerLevel(next, gzip.BestCompression)
 	})
 
+	// Probes and metrics, served by the admin listener when there is one.
+	// Metrics are public only on request, they reveal routes and traffic.
+	if len(config.GetConfig().GetAdminListen()) == 0 {
+		handleHealth(r)
+
+		if config.GetConfig().GetPublicMetrics() {
+			handleMetrics(r)
+		} else {
+			slog.Info("Metrics are not served, set adminListen or publicMetrics to expose them")
+		}
+	}
+
 	// Static files
 	r.PathPrefix("/").HandlerFunc(SPAHandler)
 
-	// 404 middleware with logging using combined logger
-	r.NotFoundHandler = handlers.CustomLoggingHandler(
-		os.Stdout,
-		http.HandlerFunc(NotFoundHandler),
-		logger.HttpLogFormater)
+	// 404 middleware with access logging
+	r.NotFoundHandler = requestIDMiddleware(metricsMiddleware(
+		logger.AccessLogHandler(http.HandlerFunc(NotFoundHandler))))
 
-	// Logging middleware
+	// Request user middleware, lets the access log see the authenticated user
 	r.Use(func(next http.Handler) http.Handler {
-		return handlers.CustomLoggingHandler(os.Stdout, next, logger.HttpLogFormater)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(logger.WithRequestUser(r.Context())))
+		})
 	})
 
+	// Request id middleware, before logging so the access log carries the id
+	r.Use(requestIDMiddleware)
+
+	// Tracing middleware, before logging so the access log carries the trace id
+	r.Use(tracingMiddleware)
+
+	// Metrics middleware
+	r.Use(metricsMiddleware)
+
+	// Access log middleware
+	r.Use(logger.AccessLogHandler)
+
 	// Recover middleware
 	r.Use(func(next http.Handler) http.Handler {
 		return handlers.RecoveryHandler(
@@ -88,13 +129,28 @@ func StartWebServer() (*http.Server, error) {
 		IdleTimeout:  time.Second * 60,
 		Handler:      h2cr, // Pass our instance of gorilla/mux in.
 		ErrorLog:     logger.DefaultErrorLogger,
+		TLSConfig:    tlsConfig,
 	}
 
-	go func() {
-		if err := srv.Serve(listener); err != nil {
-			slog.Error("Error starting server", "error", err)
-		}
-	}()
+	srv.RegisterOnShutdown(tlsCancel)
+
+	// the same router is served on every listener, Shutdown closes them all
+	for _, listener := range listeners {
+		go func() {
+			var err error
+
+			if tlsConfig != nil {
+				// certificates come from TLSConfig.GetCertificate
+				err = srv.ServeTLS(listener, "", "")
+			} else {
+				err = srv.Serve(listener)
+			}
+
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Error starting server", "address", listener.Addr(), "error", err)
+			}
+		}()
+	}
 
 	slog.Info("Web server started")
 
@@ -0,0 +1,224 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/config"
+)
+
+const tlsReloadInterval = 10 * time.Second
+
+// tlsMaterial is the certificate and client CA pool loaded from disk.
+type tlsMaterial struct {
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	digest    []byte
+}
+
+// certReloader serves the certificate and client CAs from files and reloads
+// them when the file contents change, so rotated certificates are picked up
+// without restarting. A failed reload keeps the previous material.
+type certReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	base         *tls.Config
+	current      atomic.Pointer[tlsMaterial]
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// buildTLSConfig returns the server TLS config, or nil when TLS is disabled.
+// The certificate reloader runs until ctx is done.
+func buildTLSConfig(ctx context.Context) (*tls.Config, error) {
+	c := config.GetConfig()
+
+	if c.GetTLSCertFile() == "" && c.GetTLSKeyFile() == "" {
+		if c.GetTLSClientCAFile() != "" {
+			return nil, errors.New("tlsClientCAFile requires tlsCertFile and tlsKeyFile")
+		}
+
+		return nil, nil
+	}
+
+	if c.GetTLSCertFile() == "" || c.GetTLSKeyFile() == "" {
+		return nil, errors.New("both tlsCertFile and tlsKeyFile are required for TLS")
+	}
+
+	minVersion, ok := tlsVersions[c.GetTLSMinVersion()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS minimum version: %s", c.GetTLSMinVersion())
+	}
+
+	cipherSuites, err := parseCipherSuites(c.GetTLSCipherSuites())
+	if err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if c.GetTLSClientCAFile() != "" {
+		switch c.GetTLSClientAuth() {
+		case "require", "":
+			base.ClientAuth = tls.RequireAndVerifyClientCert
+		case "optional":
+			base.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("unsupported TLS client auth: %s", c.GetTLSClientAuth())
+		}
+	}
+
+	cr := &certReloader{
+		certFile:     c.GetTLSCertFile(),
+		keyFile:      c.GetTLSKeyFile(),
+		clientCAFile: c.GetTLSClientCAFile(),
+		base:         base,
+	}
+
+	if _, err := cr.reload(); err != nil {
+		return nil, err
+	}
+
+	go cr.watch(ctx)
+
+	slog.InfoContext(ctx, "TLS enabled", "cert", cr.certFile, "client_ca", cr.clientCAFile, "min_version", c.GetTLSMinVersion())
+
+	tlsConfig := base.Clone()
+	tlsConfig.GetCertificate = cr.getCertificate
+	tlsConfig.GetConfigForClient = cr.getConfigForClient
+
+	return tlsConfig, nil
+}
+
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := map[string]uint16{}
+	for _, cs := range tls.CipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported or insecure TLS cipher suite: %s", name)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (cr *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.current.Load().cert, nil
+}
+
+func (cr *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	m := cr.current.Load()
+
+	c := cr.base.Clone()
+	c.Certificates = []tls.Certificate{*m.cert}
+	c.ClientCAs = m.clientCAs
+
+	return c, nil
+}
+
+// reload loads the files when their contents changed and reports whether
+// new material is in use.
+func (cr *certReloader) reload() (bool, error) {
+	certPEM, err := os.ReadFile(cr.certFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+
+	keyPEM, err := os.ReadFile(cr.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read TLS key: %w", err)
+	}
+
+	var caPEM []byte
+
+	if cr.clientCAFile != "" {
+		caPEM, err = os.ReadFile(cr.clientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to read TLS client CA: %w", err)
+		}
+	}
+
+	h := sha256.New()
+	h.Write(certPEM)
+	h.Write(keyPEM)
+	h.Write(caPEM)
+	digest := h.Sum(nil)
+
+	if old := cr.current.Load(); old != nil && bytes.Equal(old.digest, digest) {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	m := &tlsMaterial{cert: &cert, digest: digest}
+
+	if cr.clientCAFile != "" {
+		m.clientCAs = x509.NewCertPool()
+
+		if !m.clientCAs.AppendCertsFromPEM(caPEM) {
+			return false, errors.New("no certificates found in TLS client CA file")
+		}
+	}
+
+	cr.current.Store(m)
+
+	return true, nil
+}
+
+// watch polls the files and reloads them on change. Polling follows the
+// symlink swaps used by Kubernetes secret volumes, which file events miss.
+func (cr *certReloader) watch(ctx context.Context) {
+	ticker := time.NewTicker(tlsReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := cr.reload()
+
+			if err != nil {
+				slog.ErrorContext(ctx, "TLS certificate reload failed, keeping previous certificate", "error", err)
+			} else if changed {
+				slog.InfoContext(ctx, "TLS certificate reloaded", "cert", cr.certFile)
+			}
+		}
+	}
+}
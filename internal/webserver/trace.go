@@ -0,0 +1,68 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"github.com/kazimsarikaya/go_react_mui/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingMiddleware continues the trace of an incoming traceparent header or
+// starts a new one, with a server span named after the matched route.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := "unmatched"
+
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tpl, err := cr.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		ctx, span := tracing.Start(ctx, r.Method+" "+route, trace.SpanKindServer,
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("network.protocol.version", r.Proto),
+			attribute.String("user_agent.original", r.UserAgent()),
+		)
+		defer span.End()
+
+		sr := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(sr, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", sr.statusCode()))
+
+		if sr.statusCode() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sr.statusCode()))
+		}
+	})
+}
+
+// startClientSpan starts a span of an outgoing GET request.
+func startClientSpan(ctx context.Context, name string, rawURL string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", http.MethodGet),
+		attribute.String("url.full", rawURL),
+	}
+
+	if u, err := url.Parse(rawURL); err == nil {
+		attrs = append(attrs, attribute.String("server.address", u.Hostname()))
+	}
+
+	return tracing.Start(ctx, name, trace.SpanKindClient, attrs...)
+}
@@ -0,0 +1,235 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/kazimsarikaya/go_react_mui/internal/logger"
+	"github.com/kazimsarikaya/go_react_mui/internal/tracing"
+	"github.com/kazimsarikaya/go_react_mui/internal/tracing/tracingtest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	testTraceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID = "00f067aa0ba902b7"
+)
+
+// setupTestTracing exports spans to a test collector with the sampler and
+// captures the logs, both are restored when the test ends.
+func setupTestTracing(t *testing.T, sampler string) (*tracingtest.Collector, func(), *bytes.Buffer) {
+	t.Helper()
+
+	collector := tracingtest.NewCollector(t)
+
+	shutdown, err := tracing.Setup(tracing.Options{Endpoint: collector.URL, Sampler: sampler})
+
+	if err != nil {
+		t.Fatalf("setup tracing: %v", err)
+	}
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	flush := func() {
+		if err := shutdown(context.Background()); err != nil {
+			t.Fatalf("flush spans: %v", err)
+		}
+	}
+
+	logs := &bytes.Buffer{}
+	prev := slog.Default()
+	slog.SetDefault(slog.New(logger.NewContextHandler(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	return collector, flush, logs
+}
+
+func TestTracingExportsRequestSpans(t *testing.T) {
+	tests := []struct {
+		name        string
+		sampler     string
+		traceparent string
+		exported    bool
+	}{
+		{name: "sampled parent", sampler: "parentbased_always_on", traceparent: "00-" + testTraceID + "-" + testParentID + "-01", exported: true},
+		{name: "unsampled parent", sampler: "parentbased_always_on", traceparent: "00-" + testTraceID + "-" + testParentID + "-00"},
+		{name: "new trace", sampler: "always_on", exported: true},
+		{name: "sampler off", sampler: "always_off"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := setupTestAuth(t)
+			collector, flush, logs := setupTestTracing(t, tt.sampler)
+
+			router := mux.NewRouter()
+			router.Use(tracingMiddleware)
+			router.HandleFunc("/api", ApiHandler).Methods(http.MethodPost)
+
+			token := signTestToken(t, jwt.SigningMethodRS256, testKid, jwt.MapClaims{
+				"iss":                idp.URL,
+				"aud":                testAudience,
+				"exp":                time.Now().Add(time.Hour).Unix(),
+				"preferred_username": "alice",
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/api", strings.NewReader(`{"action":"test_auth"}`))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Authorization", "Bearer "+token)
+
+			if tt.traceparent != "" {
+				r.Header.Set("traceparent", tt.traceparent)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			flush()
+
+			// the IdP requests carry the trace, sampled or not
+			traceparents := map[string]string{}
+
+			for _, path := range []string{"/.well-known/openid-configuration", "/jwks"} {
+				v, _ := idp.traceparents.Load(path)
+				traceparent, _ := v.(string)
+				parts := strings.Split(traceparent, "-")
+
+				if len(parts) != 4 {
+					t.Fatalf("%s: traceparent = %q", path, traceparent)
+				}
+
+				if tt.traceparent != "" && (parts[1] != testTraceID || parts[3] != tt.traceparent[len(tt.traceparent)-2:]) {
+					t.Errorf("%s: traceparent = %q does not continue %q", path, traceparent, tt.traceparent)
+				}
+
+				traceparents[path] = traceparent
+			}
+
+			traceID := strings.Split(traceparents["/jwks"], "-")[1]
+
+			if !logged(t, logs, "Key set refreshed", traceID) {
+				t.Errorf("key set log has no trace_id %s: %s", traceID, logs)
+			}
+
+			if !tt.exported {
+				if spans := collector.Spans(); len(spans) != 0 {
+					t.Errorf("exported %d spans, want none", len(spans))
+				}
+
+				return
+			}
+
+			server := mustSpan(t, collector, "POST /api")
+			dispatch := mustSpan(t, collector, "api dispatch")
+			validate := mustSpan(t, collector, "validate token")
+			fetchConfig := mustSpan(t, collector, "fetch OIDC configuration")
+			fetchJWKS := mustSpan(t, collector, "fetch JWKS")
+			action := mustSpan(t, collector, "action test_auth")
+
+			if server.GetKind() != tracepb.Span_SPAN_KIND_SERVER || fetchJWKS.GetKind() != tracepb.Span_SPAN_KIND_CLIENT {
+				t.Errorf("span kinds = %v, %v", server.GetKind(), fetchJWKS.GetKind())
+			}
+
+			if tt.traceparent != "" && spanID(server.GetParentSpanId()) != testParentID {
+				t.Errorf("server span parent = %s, want %s", spanID(server.GetParentSpanId()), testParentID)
+			}
+
+			parents := []struct {
+				child, parent *tracepb.Span
+			}{
+				{dispatch, server},
+				{validate, dispatch},
+				{fetchConfig, validate},
+				{fetchJWKS, validate},
+				{action, dispatch},
+			}
+
+			for _, p := range parents {
+				if !bytes.Equal(p.child.GetParentSpanId(), p.parent.GetSpanId()) {
+					t.Errorf("%s is not a child of %s", p.child.GetName(), p.parent.GetName())
+				}
+
+				if traceIDString(p.child.GetTraceId()) != traceID {
+					t.Errorf("%s has trace id %s, want %s", p.child.GetName(), traceIDString(p.child.GetTraceId()), traceID)
+				}
+			}
+
+			// the IdP requests are sent in the context of their client spans
+			for path, span := range map[string]*tracepb.Span{
+				"/.well-known/openid-configuration": fetchConfig,
+				"/jwks":                             fetchJWKS,
+			} {
+				if got := strings.Split(traceparents[path], "-")[2]; got != spanID(span.GetSpanId()) {
+					t.Errorf("%s: traceparent parent = %s, want %s span %s", path, got, span.GetName(), spanID(span.GetSpanId()))
+				}
+			}
+		})
+	}
+}
+
+func mustSpan(t *testing.T, collector *tracingtest.Collector, name string) *tracepb.Span {
+	t.Helper()
+
+	span := collector.Span(name)
+
+	if span == nil {
+		t.Fatalf("span %q is not exported", name)
+	}
+
+	return span
+}
+
+func spanID(id []byte) string {
+	var sid trace.SpanID
+	copy(sid[:], id)
+
+	return sid.String()
+}
+
+func traceIDString(id []byte) string {
+	var tid trace.TraceID
+	copy(tid[:], id)
+
+	return tid.String()
+}
+
+// logged reports whether a JSON log record with the message has the trace id.
+func logged(t *testing.T, logs *bytes.Buffer, msg string, traceID string) bool {
+	t.Helper()
+
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var record map[string]interface{}
+
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decode log record %q: %v", line, err)
+		}
+
+		if record["msg"] == msg && record["trace_id"] == traceID {
+			return true
+		}
+	}
+
+	return false
+}
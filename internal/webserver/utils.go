@@ -7,9 +7,11 @@ package webserver
 import (
 	"bytes"
 	"encoding/json"
+	"mime"
+	"net/http"
 )
 
-// nolint:unused // this function is intentionally unused for now, after using please remove this comment
+// transcode converts in to out through JSON, unknown fields are rejected.
 func transcode(in, out interface{}) error {
 	buf := new(bytes.Buffer)
 	err := json.NewEncoder(buf).Encode(in)
@@ -18,5 +20,20 @@ func transcode(in, out interface{}) error {
 		return err
 	}
 
-	return json.NewDecoder(buf).Decode(out)
+	dec := json.NewDecoder(buf)
+	dec.DisallowUnknownFields()
+
+	return dec.Decode(out)
+}
+
+// requestMediaType returns the media type of the request body without
+// parameters like charset, empty when it is missing or invalid.
+func requestMediaType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if err != nil {
+		return ""
+	}
+
+	return mediaType
 }
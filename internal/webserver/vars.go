@@ -9,3 +9,4 @@ import (
 )
 
 var staticHandler http.Handler = nil
+var staticFS http.FileSystem = nil
@@ -5,28 +5,23 @@
 package webserver
 
 import (
-	"encoding/json"
-	"log/slog"
-	"net/http"
+	"context"
 
 	"github.com/kazimsarikaya/go_react_mui/internal/config"
 )
 
-func getVersion(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
-	return func() {
+type VersionRequest struct{}
 
-		json, err := json.Marshal(map[string]interface{}{"version": config.GetConfig().GetVersion(), "build_time": config.GetConfig().GetBuildTime(), "go_version": config.GetConfig().GetGoVersion()})
-
-		if err != nil {
-			sendError(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		_, err = w.Write(json)
-
-		if err != nil {
-			slog.Error("Error writing response", "error", err)
+type VersionResponse struct {
+	Version   string `json:"version"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
 
-		}
-	}
+func getVersion(ctx context.Context, req VersionRequest) (VersionResponse, error) {
+	return VersionResponse{
+		Version:   config.GetConfig().GetVersion(),
+		BuildTime: config.GetConfig().GetBuildTime(),
+		GoVersion: config.GetConfig().GetGoVersion(),
+	}, nil
 }
@@ -0,0 +1,569 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/kube"
+)
+
+const (
+	watchRouteName = "watch"
+
+	watchMaxSubscriptions = 16
+	watchListPageSize     = 500
+
+	// events buffered between the watches and a slow client
+	watchEventBuffer = 256
+	// how long a full buffer may hold back the watches before the stream is
+	// dropped, the client resumes with Last-Event-ID
+	watchSlowClientTimeout = 10 * time.Second
+	watchWriteTimeout      = 10 * time.Second
+	watchHeartbeatInterval = 15 * time.Second
+
+	// the API server ends watches after a random 5-10 minutes, like client-go
+	// reflectors do, so load spreads when many watches restart together
+	watchMinTimeout = 5 * time.Minute
+
+	watchRetryMin = 1 * time.Second
+	watchRetryMax = 30 * time.Second
+
+	// reconnect delay advised to EventSource clients
+	watchClientRetry = 3 * time.Second
+)
+
+var (
+	errWatchSlowClient  = errors.New("client does not keep up with the watch")
+	errWatchTokenExpiry = errors.New("token of the watch expired")
+)
+
+// WatchStreamEvent is the data of an event of the watch stream.
+type WatchStreamEvent struct {
+	Subscription    string          `json:"subscription"`
+	Type            string          `json:"type"`
+	ResourceVersion string          `json:"resource_version,omitempty"`
+	Object          json.RawMessage `json:"object,omitempty"`
+	Error           *APIError       `json:"error,omitempty"`
+}
+
+// Stream event types besides the watch events of the API server.
+const (
+	// RESET starts the (re)listing of a subscription, clients drop its objects
+	watchEventReset = "RESET"
+	// SYNCED ends the listing, following events are changes
+	watchEventSynced = "SYNCED"
+)
+
+// watchMessage is sent from a subscription to the stream writer.
+type watchMessage struct {
+	sub   int
+	event WatchStreamEvent
+	// setRV updates the resume version of the subscription
+	setRV bool
+	rv    string
+}
+
+type watchSubscription struct {
+	index         int
+	id            string
+	resource      kube.Resource
+	namespace     string
+	labelSelector string
+	client        *kube.Client
+	messages      chan<- watchMessage
+	cancel        context.CancelCauseFunc
+}
+
+// WatchHandler streams Kubernetes watches as server-sent events. The query
+// selects resource (repeated) and namespace (repeated, all namespaces when
+// missing) with an optional labelSelector. Every resource and namespace pair
+// is a subscription watched as the caller, so cluster RBAC authorizes each
+// one; a denied subscription gets an ERROR event while the others go on.
+//
+// Watches need the caller's identity on the API server, so they are refused
+// when every call is made as the service account.
+//
+// Event ids hold the resource versions of all subscriptions, a client which
+// reconnects with Last-Event-ID and the same query resumes without listing
+// again. EventSource can not send the Authorization header, browsers read
+// the stream with fetch.
+func WatchHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := authenticateRequest(w, r)
+
+	if !ok {
+		return
+	}
+
+	client, kubeCtx, err := kubeClient(WithPrincipal(r.Context(), principal))
+
+	if err != nil {
+		sendAPIError(w, r, err)
+		return
+	}
+
+	// cluster RBAC would authorize the service account, not the caller
+	if client.AuthMode() == kube.AuthModeServiceAccount {
+		sendAPIError(w, r, NewAPIError(ErrForbidden, "Kubernetes watches are not available with the serviceaccount auth mode"))
+		return
+	}
+
+	subs, err := parseWatchSubscriptions(r.URL.Query())
+
+	if err != nil {
+		sendAPIError(w, r, err)
+		return
+	}
+
+	rvs := parseWatchEventID(r.Header.Get("Last-Event-ID"), len(subs))
+
+	ctx, cancel := context.WithCancelCause(kubeCtx)
+	defer cancel(nil)
+
+	// the stream must not outlive the token it was authorized with
+	if exp, ok := principal.Claims["exp"].(float64); ok {
+		var cancelExpiry context.CancelFunc
+
+		ctx, cancelExpiry = context.WithDeadlineCause(ctx, time.Unix(int64(exp), 0), errWatchTokenExpiry)
+		defer cancelExpiry()
+	}
+
+	watchStreamsActive.Inc()
+	defer watchStreamsActive.Dec()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// disable response buffering of nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	messages := make(chan watchMessage, watchEventBuffer)
+
+	var wg sync.WaitGroup
+
+	for i := range subs {
+		subs[i].client = client
+		subs[i].messages = messages
+		subs[i].cancel = cancel
+
+		wg.Add(1)
+
+		go func(s *watchSubscription, rv string) {
+			defer wg.Done()
+			s.run(ctx, rv)
+		}(&subs[i], rvs[i])
+	}
+
+	// the stream ends when every subscription stopped
+	go func() {
+		wg.Wait()
+		close(messages)
+	}()
+
+	slog.DebugContext(ctx, "Watch stream started", "subscriptions", len(subs))
+
+	if err := writeWatchRetry(rc, w); err != nil {
+		cancel(err)
+		return
+	}
+
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			cause := context.Cause(ctx)
+
+			if errors.Is(cause, errWatchTokenExpiry) {
+				// let the client renew the token before it reconnects
+				_ = writeWatchEvent(rc, w, formatWatchEventID(rvs), WatchStreamEvent{
+					Type:  kube.EventError,
+					Error: NewAPIError(ErrUnauthorized, "Token expired"),
+				})
+			}
+
+			if !errors.Is(cause, context.Canceled) {
+				slog.InfoContext(ctx, "Watch stream ended", "reason", cause)
+			}
+
+			return
+		case <-heartbeat.C:
+			if err := writeWatchComment(rc, w, "ping"); err != nil {
+				cancel(err)
+				return
+			}
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			if msg.setRV {
+				rvs[msg.sub] = msg.rv
+			}
+
+			watchEventsTotal.WithLabelValues(subs[msg.sub].resource.Name, msg.event.Type).Inc()
+
+			if err := writeWatchEvent(rc, w, formatWatchEventID(rvs), msg.event); err != nil {
+				cancel(err)
+				return
+			}
+		}
+	}
+}
+
+// parseWatchSubscriptions builds the subscriptions of the query. Cluster
+// scoped resources ignore the namespaces.
+func parseWatchSubscriptions(q url.Values) ([]watchSubscription, error) {
+	resources := q["resource"]
+
+	if len(resources) == 0 {
+		return nil, NewAPIError(ErrInvalidParameters, "resource parameter is missing")
+	}
+
+	namespaces := q["namespace"]
+
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+
+	for _, ns := range namespaces {
+		if ns == "" {
+			continue
+		}
+
+		if err := kube.ValidateNamespace(ns); err != nil {
+			return nil, NewAPIError(ErrInvalidParameters, err.Error())
+		}
+	}
+
+	var subs []watchSubscription
+
+	seen := map[string]bool{}
+
+	for _, name := range resources {
+		resource, ok := kube.LookupResource(name)
+
+		if !ok {
+			return nil, NewAPIError(ErrInvalidParameters, fmt.Sprintf("resource %q can not be watched", name))
+		}
+
+		for _, ns := range namespaces {
+			id := resource.Name
+
+			if !resource.Namespaced {
+				ns = ""
+			} else if ns != "" {
+				id += "/" + ns
+			}
+
+			if seen[id] {
+				continue
+			}
+
+			seen[id] = true
+
+			subs = append(subs, watchSubscription{
+				index:         len(subs),
+				id:            id,
+				resource:      resource,
+				namespace:     ns,
+				labelSelector: q.Get("labelSelector"),
+			})
+		}
+	}
+
+	if len(subs) > watchMaxSubscriptions {
+		return nil, NewAPIError(ErrInvalidParameters, fmt.Sprintf("more than %d subscriptions", watchMaxSubscriptions))
+	}
+
+	return subs, nil
+}
+
+// formatWatchEventID joins the resource versions of the subscriptions.
+func formatWatchEventID(rvs []string) string {
+	parts := make([]string, len(rvs))
+
+	for i, rv := range rvs {
+		parts[i] = url.QueryEscape(rv)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// parseWatchEventID returns the resource versions of a Last-Event-ID. An id
+// which does not fit the subscriptions starts them from scratch.
+func parseWatchEventID(id string, count int) []string {
+	rvs := make([]string, count)
+
+	if id == "" {
+		return rvs
+	}
+
+	parts := strings.Split(id, ",")
+
+	if len(parts) != count {
+		return rvs
+	}
+
+	for i, part := range parts {
+		rv, err := url.QueryUnescape(part)
+
+		if err != nil {
+			return make([]string, count)
+		}
+
+		rvs[i] = rv
+	}
+
+	return rvs
+}
+
+// run lists and watches the subscription until ctx is done or the API server
+// refuses it. Broken watches are resumed from the last seen resource version,
+// an expired version lists again.
+func (s *watchSubscription) run(ctx context.Context, rv string) {
+	backoff := watchRetryMin
+
+	for {
+		var err error
+
+		if rv == "" {
+			rv, err = s.list(ctx)
+		}
+
+		if err == nil {
+			started := time.Now()
+
+			rv, err = s.watch(ctx, rv)
+
+			// a watch which ran a while was healthy, one ended by its
+			// timeout is restarted at once
+			if time.Since(started) >= watchRetryMin {
+				backoff = watchRetryMin
+
+				if errors.Is(err, io.EOF) {
+					continue
+				}
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		var statusErr *kube.StatusError
+
+		if errors.As(err, &statusErr) {
+			switch {
+			case statusErr.Code == http.StatusGone:
+				slog.DebugContext(ctx, "Watch resource version expired, listing again", "subscription", s.id)
+				rv = ""
+				continue
+			case statusErr.Code < http.StatusInternalServerError && statusErr.Code != http.StatusTooManyRequests:
+				s.fail(ctx, err)
+				return
+			}
+		}
+
+		if errors.Is(err, kube.ErrNoUser) || errors.Is(err, kube.ErrReservedUser) {
+			s.fail(ctx, err)
+			return
+		}
+
+		slog.WarnContext(ctx, "Kubernetes watch failed, retrying", "subscription", s.id, "error", err, "backoff", backoff)
+
+		// full jitter keeps reconnecting streams apart
+		delay := backoff/2 + rand.N(backoff/2+1)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		backoff = min(backoff*2, watchRetryMax)
+	}
+}
+
+// list sends the current objects of the subscription between RESET and
+// SYNCED and returns the resource version to watch from.
+func (s *watchSubscription) list(ctx context.Context) (string, error) {
+	if !s.send(ctx, watchMessage{event: WatchStreamEvent{Type: watchEventReset}, setRV: true}) {
+		return "", ctx.Err()
+	}
+
+	opts := kube.ListOptions{LabelSelector: s.labelSelector, Limit: watchListPageSize}
+
+	for {
+		list, err := s.client.List(ctx, s.resource, s.namespace, opts)
+
+		if err != nil {
+			return "", err
+		}
+
+		for _, item := range list.Items {
+			if !s.send(ctx, watchMessage{event: WatchStreamEvent{Type: kube.EventAdded, Object: item}}) {
+				return "", ctx.Err()
+			}
+		}
+
+		if list.Metadata.Continue == "" {
+			rv := list.Metadata.ResourceVersion
+
+			event := WatchStreamEvent{Type: watchEventSynced, ResourceVersion: rv}
+
+			if !s.send(ctx, watchMessage{event: event, setRV: true, rv: rv}) {
+				return "", ctx.Err()
+			}
+
+			return rv, nil
+		}
+
+		opts.Continue = list.Metadata.Continue
+	}
+}
+
+// watch forwards the events of one watch and returns the last resource
+// version with the error which ended it.
+func (s *watchSubscription) watch(ctx context.Context, rv string) (string, error) {
+	timeout := watchMinTimeout + rand.N(watchMinTimeout)
+
+	watcher, err := s.client.Watch(ctx, s.resource, s.namespace, kube.WatchOptions{
+		ResourceVersion: rv,
+		TimeoutSeconds:  int(timeout.Seconds()),
+		LabelSelector:   s.labelSelector,
+	})
+
+	if err != nil {
+		return rv, err
+	}
+
+	defer watcher.Close()
+
+	// Next blocks on the response body, closing it ends the watch at once
+	stop := context.AfterFunc(ctx, func() {
+		watcher.Close()
+	})
+	defer stop()
+
+	for {
+		e, err := watcher.Next()
+
+		if err != nil {
+			return rv, err
+		}
+
+		if v := e.ResourceVersion(); v != "" {
+			rv = v
+		}
+
+		event := WatchStreamEvent{Type: e.Type, ResourceVersion: rv}
+
+		// bookmarks only carry the resource version
+		if e.Type != kube.EventBookmark {
+			event.Object = e.Object
+		}
+
+		if !s.send(ctx, watchMessage{event: event, setRV: true, rv: rv}) {
+			return rv, ctx.Err()
+		}
+	}
+}
+
+// fail reports an error the subscription does not recover from.
+func (s *watchSubscription) fail(ctx context.Context, err error) {
+	var apiErr *APIError
+
+	errors.As(kubeAPIError(ctx, err), &apiErr)
+
+	slog.InfoContext(ctx, "Watch subscription stopped", "subscription", s.id, "error", err)
+
+	s.send(ctx, watchMessage{event: WatchStreamEvent{Type: kube.EventError, Error: apiErr}})
+}
+
+// send queues the message for the client. A full queue holds back the watch
+// until the client catches up, or drops the stream when it does not.
+func (s *watchSubscription) send(ctx context.Context, msg watchMessage) bool {
+	msg.sub = s.index
+	msg.event.Subscription = s.id
+
+	select {
+	case s.messages <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	default:
+	}
+
+	timer := time.NewTimer(watchSlowClientTimeout)
+	defer timer.Stop()
+
+	select {
+	case s.messages <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		s.cancel(errWatchSlowClient)
+		return false
+	}
+}
+
+func writeWatchRetry(rc *http.ResponseController, w io.Writer) error {
+	return writeWatchFrame(rc, w, []byte(fmt.Sprintf("retry: %d\n\n", watchClientRetry.Milliseconds())))
+}
+
+func writeWatchComment(rc *http.ResponseController, w io.Writer, comment string) error {
+	return writeWatchFrame(rc, w, []byte(": "+comment+"\n\n"))
+}
+
+// writeWatchEvent writes a server-sent event, the JSON data has no newlines.
+// Events keep the default message name, the type is part of the data so an
+// ERROR is not mistaken for a connection error by EventSource.
+func writeWatchEvent(rc *http.ResponseController, w io.Writer, id string, event WatchStreamEvent) error {
+	data, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("id: " + id + "\n")
+	buf.WriteString("data: ")
+	buf.Write(data)
+	buf.WriteString("\n\n")
+
+	return writeWatchFrame(rc, w, buf.Bytes())
+}
+
+// writeWatchFrame writes and flushes a frame. Each write gets its own
+// deadline, the server's write timeout would end the stream otherwise.
+func writeWatchFrame(rc *http.ResponseController, w io.Writer, frame []byte) error {
+	if err := rc.SetWriteDeadline(time.Now().Add(watchWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+
+	return rc.Flush()
+}
@@ -0,0 +1,72 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseWatchSubscriptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+		code  ErrorCode
+	}{
+		{name: "all namespaces", query: "resource=pods", want: []string{"pods"}},
+		{name: "namespaces", query: "resource=pods&namespace=a&namespace=b", want: []string{"pods/a", "pods/b"}},
+		{name: "duplicates", query: "resource=pods&resource=pods&namespace=a&namespace=a", want: []string{"pods/a"}},
+		{name: "cluster scoped ignores namespaces", query: "resource=namespaces&resource=pods&namespace=a&namespace=b", want: []string{"namespaces", "pods/a", "pods/b"}},
+		{name: "missing resource", query: "namespace=a", code: ErrInvalidParameters},
+		{name: "unknown resource", query: "resource=secrets", code: ErrInvalidParameters},
+		{name: "dot dot namespace", query: "resource=pods&namespace=..", code: ErrInvalidParameters},
+		{name: "escaped slash namespace", query: "resource=pods&namespace=a%252Fb", code: ErrInvalidParameters},
+		{name: "slash namespace", query: "resource=pods&namespace=a%2Fb", code: ErrInvalidParameters},
+		{name: "invalid namespace of cluster scoped resource", query: "resource=namespaces&namespace=A", code: ErrInvalidParameters},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			subs, err := parseWatchSubscriptions(q)
+
+			if tt.code != "" {
+				var apiErr *APIError
+
+				if !errors.As(err, &apiErr) || apiErr.Code != tt.code {
+					t.Fatalf("parseWatchSubscriptions() error = %v, want %s", err, tt.code)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseWatchSubscriptions() error = %v", err)
+			}
+
+			var ids []string
+
+			for i, s := range subs {
+				if s.index != i {
+					t.Errorf("subscription %s has index %d, want %d", s.id, s.index, i)
+				}
+
+				ids = append(ids, s.id)
+			}
+
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("subscriptions = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
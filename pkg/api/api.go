@@ -0,0 +1,84 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package api
+
+import (
+	"context"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/webserver"
+)
+
+// Validator lets a request check its fields once decoded, an error fails the
+// call with invalid_parameters unless it is an APIError.
+type Validator = webserver.Validator
+
+// ActionFunc is the handler of an action registered with RegisterAction.
+type ActionFunc[Req any, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// ActionOption is an option of RegisterAction.
+type ActionOption = webserver.ActionOption
+
+// Principal is the caller of an action registered with WithAuth.
+type Principal = webserver.Principal
+
+// APIError is the error actions return to choose what clients see, other
+// errors reach them as internal errors.
+type APIError = webserver.APIError
+
+// ErrorCode selects the code and HTTP status of an APIError.
+type ErrorCode = webserver.ErrorCode
+
+const (
+	ErrBadRequest           = webserver.ErrBadRequest
+	ErrInvalidParameters    = webserver.ErrInvalidParameters
+	ErrUnknownAction        = webserver.ErrUnknownAction
+	ErrUnauthorized         = webserver.ErrUnauthorized
+	ErrForbidden            = webserver.ErrForbidden
+	ErrNotFound             = webserver.ErrNotFound
+	ErrMethodNotAllowed     = webserver.ErrMethodNotAllowed
+	ErrConflict             = webserver.ErrConflict
+	ErrPayloadTooLarge      = webserver.ErrPayloadTooLarge
+	ErrUnsupportedMediaType = webserver.ErrUnsupportedMediaType
+	ErrRateLimited          = webserver.ErrRateLimited
+	ErrInternal             = webserver.ErrInternal
+	ErrUnavailable          = webserver.ErrUnavailable
+)
+
+// RegisterAction adds an api action to the server from outside of this
+// module, see the built in actions for examples. Register them in an init
+// function, before the server starts; a duplicate name panics.
+func RegisterAction[Req any, Resp any](name string, fn ActionFunc[Req, Resp], opts ...ActionOption) {
+	webserver.RegisterAction(name, webserver.ActionFunc[Req, Resp](fn), opts...)
+}
+
+// WithAuth lets only callers with a valid token in.
+func WithAuth() ActionOption {
+	return webserver.WithAuth()
+}
+
+// WithDescription describes the action in the action catalog.
+func WithDescription(description string) ActionOption {
+	return webserver.WithDescription(description)
+}
+
+// RequireAnyOf lets in callers in one of the groups, it implies WithAuth.
+func RequireAnyOf(groups ...string) ActionOption {
+	return webserver.RequireAnyOf(groups...)
+}
+
+// RequireAllOf lets in callers in all of the groups, it implies WithAuth.
+func RequireAllOf(groups ...string) ActionOption {
+	return webserver.RequireAllOf(groups...)
+}
+
+// NewAPIError returns an APIError, the message is sent to clients.
+func NewAPIError(code ErrorCode, message string) *APIError {
+	return webserver.NewAPIError(code, message)
+}
+
+// PrincipalFromContext returns the caller of an authenticated action.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	return webserver.PrincipalFromContext(ctx)
+}
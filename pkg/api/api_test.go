@@ -0,0 +1,53 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package api
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kazimsarikaya/go_react_mui/internal/webserver"
+)
+
+type testGreetRequest struct {
+	Name string `json:"name"`
+}
+
+type testGreetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func init() {
+	RegisterAction("api_test_greet", func(ctx context.Context, req testGreetRequest) (testGreetResponse, error) {
+		if req.Name == "" {
+			return testGreetResponse{}, NewAPIError(ErrInvalidParameters, "name is missing")
+		}
+
+		return testGreetResponse{Greeting: "hello " + req.Name}, nil
+	}, WithDescription("Greets the caller"), RequireAnyOf("admin"), RequireAllOf("staff"))
+}
+
+func TestRegisterAction(t *testing.T) {
+	for _, a := range webserver.ActionCatalog() {
+		if a.Name != "api_test_greet" {
+			continue
+		}
+
+		want := webserver.ActionAuth{Required: true, AnyOf: []string{"admin"}, AllOf: []string{"staff"}}
+
+		if a.Description != "Greets the caller" || !reflect.DeepEqual(a.Auth, want) {
+			t.Errorf("catalog entry = %+v, want description and auth %+v", a, want)
+		}
+
+		if a.Request == nil || a.Response == nil {
+			t.Errorf("catalog entry has no request or response schema: %+v", a)
+		}
+
+		return
+	}
+
+	t.Fatal("action is not in the catalog")
+}